@@ -0,0 +1,92 @@
+package pengine
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// all returns a sequence of the answers in as, closing it when iteration stops.
+// If the query fails with an error other than ErrFailed, it is yielded last with the zero value of T.
+func all[T any](ctx context.Context, as Answers[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer as.Close()
+		for as.Next(ctx) {
+			if !yield(as.Current(), nil) {
+				return
+			}
+		}
+		if err := as.Err(); err != nil && !errors.Is(err, ErrFailed) {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// All returns a sequence of this query's answers for use with range.
+// The query is closed (stopped) when the loop exits, including when it breaks early.
+// If the query fails, the sequence is empty. Other errors are yielded last.
+//
+//	for sol, err := range answers.All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(sol["X"])
+//	}
+func (as *iterator[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return all[T](ctx, as)
+}
+
+// All returns a sequence of this query's answers for use with range.
+// See Answers.All.
+func (p *prologAnswers) All(ctx context.Context) iter.Seq2[engine.Term, error] {
+	return all[engine.Term](ctx, p)
+}
+
+// All returns a sequence of this query's answers, returning the pengine to the pool when the loop exits.
+func (as *pooled[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return all[T](ctx, as)
+}
+
+// AskAll is like Ask, but returns a sequence of answers for use with range.
+// The query is asked when iteration begins, and stopped if the loop exits early.
+// Errors creating the pengine or running the query are yielded.
+//
+//	for sol, err := range pengine.AskAll[pengine.Solution](ctx, client, "between(1,3,X)") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(sol["X"])
+//	}
+func AskAll[T any](ctx context.Context, c Client, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		as, err := Ask[T](ctx, c, query, args...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		as.All(ctx)(yield)
+	}
+}
+
+// AskAll is like Ask, but returns a sequence of answers for use with range.
+// See the AskAll function for details.
+func (c Client) AskAll(ctx context.Context, query string, args ...any) iter.Seq2[Solution, error] {
+	return AskAll[Solution](ctx, c, query, args...)
+}
+
+// AskPrologAll is like AskProlog, but returns a sequence of answers for use with range.
+// See the AskAll function for details.
+func AskPrologAll(ctx context.Context, c Client, query string, args ...any) iter.Seq2[engine.Term, error] {
+	return func(yield func(engine.Term, error) bool) {
+		as, err := AskProlog(ctx, c, query, args...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		as.All(ctx)(yield)
+	}
+}
@@ -0,0 +1,81 @@
+package pengine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	c := srv.client()
+
+	t.Run("complete", func(t *testing.T) {
+		var got []int
+		for sol, err := range c.AskAll(ctx, "between(1,3,X)") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, _ := sol["X"].Number.Int64()
+			got = append(got, int(n))
+		}
+		if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+			t.Error("unexpected answers:", got)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		for _, err := range c.AskAll(ctx, "between(1,100,X)") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if n := srv.alive(); n != 0 {
+			t.Error("query should be stopped and its pengine destroyed. alive:", n)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var errs int
+		for _, err := range AskAll[Solution](ctx, c, "nope(X)") {
+			if err == nil {
+				t.Error("want error")
+			}
+			errs++
+		}
+		if errs != 1 {
+			t.Error("want 1 error, got:", errs)
+		}
+	})
+
+	t.Run("engine", func(t *testing.T) {
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		as, err := eng.Ask(ctx, "between(1,100,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range as.All(ctx) {
+			break
+		}
+		// the engine is free to ask again
+		as, err = eng.Ask(ctx, "between(1,2,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range as.All(ctx) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		if n != 2 {
+			t.Error("want 2 answers, got:", n)
+		}
+	})
+}
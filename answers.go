@@ -3,7 +3,14 @@ package pengine
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"iter"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/ichiban/prolog/engine"
 )
 
 // Answers is an iterator of query results.
@@ -35,6 +42,12 @@ type Answers[T any] interface {
 	Close() error
 	// Cumulative returns the cumulative time taken by this query, as reported by pengines.
 	Cumulative() time.Duration
+	// Stats returns statistics about this query such as the number of requests made.
+	Stats() Stats
+	// All returns a sequence of the remaining answers for use with range.
+	// The query is closed when the loop exits, so breaking early stops it.
+	// If the query fails, the sequence is empty. Other errors are yielded last.
+	All(context.Context) iter.Seq2[T, error]
 	// Engine returns this query's underlying Engine.
 	Engine() *Engine
 	// Err returns the error encountered by this query.
@@ -52,32 +65,79 @@ type answer struct {
 	Projection []string        `json:"projection"`
 	Time       float64         `json:"time"` // time taken
 	Code       string          `json:"code"` // error code
+	Arg1       json.RawMessage `json:"arg1"` // error code argument
+	Arg2       json.RawMessage `json:"arg2"` // error code argument
 	OpenLimit  int             `json:"slave_limit"`
 	Answer     *answer         `json:"answer"`
 }
 
 // iterator is an iterator for query results.
+// It is safe for concurrent use.
 type iterator[T any] struct {
 	eng  *Engine
+	self responder // the outermost query type embedding this
+
+	mu   sync.Mutex // protects the fields below
 	buf  []T
 	cur  T
 	more bool
+	pull bool    // true if more events are waiting on the server
 	good int     // count of successes
 	bad  int     // count of failures
 	cum  float64 // cumulative time taken
 	err  error
+
+	prompt   *Prompt // pending prompt
+	onOutput func(Output)
+	onPrompt PromptHandler
+
+	stats queryStats
 }
 
 func (as *iterator[T]) Engine() *Engine {
 	return as.eng
 }
 
-func newIterator[T any](e *Engine, a answer) (*iterator[T], error) {
+func newIterator[T any](ctx context.Context, e *Engine) *iterator[T] {
 	as := &iterator[T]{
-		eng: e,
+		eng:      e,
+		onOutput: outputFrom(ctx),
+		onPrompt: promptFrom(ctx),
+	}
+	as.self = as
+	return as
+}
+
+// startIterator starts iterating through the answers of a newly created pengine.
+func startIterator[T any](ctx context.Context, e *Engine, a answer) (*iterator[T], error) {
+	as := newIterator[T](ctx, e)
+	if err := e.claim(as); err != nil {
+		return nil, err
+	}
+	return as, as.start(a)
+}
+
+// start handles the first event of this query.
+func (as *iterator[T]) start(a answer) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
+	return as.handle(a)
+}
+
+// deliver handles an event received by Engine.Events.
+// The poller has already pulled or replied, so any pending pull or prompt is over.
+func (as *iterator[T]) deliver(a answer) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
+	as.pull = false
+	as.prompt = nil
+	if err := as.handle(a); err != nil {
+		as.err = err
+		return err
 	}
-	err := as.handle(a)
-	return as, err
+	return nil
 }
 
 func (as *iterator[T]) handle(a answer) error {
@@ -85,18 +145,25 @@ func (as *iterator[T]) handle(a answer) error {
 		return err
 	}
 
+	if a.Event != "success" {
+		as.eng.logEvent(a.Event)
+	}
+
 	switch a.Event {
 	case "success":
-		var data []T
-		if err := json.Unmarshal(a.Data, &data); err != nil {
+		data, err := decodeAnswers[T](as.eng.client, a.Data)
+		if err != nil {
 			return err
 		}
+		as.eng.logEvent(a.Event, slog.Int("solutions", len(data)), slog.Bool("more", a.More), slog.Float64("time", a.Time))
+		as.stats.chunk(len(data), a.Time)
 		as.buf = append(as.buf, data...)
 		as.good += len(data)
 		as.more = a.More
 		as.cum += a.Time
 	case "failure":
 		as.bad++
+		as.more = false
 		as.cum += a.Time
 	case "destroy":
 		defer as.eng.die()
@@ -111,16 +178,38 @@ func (as *iterator[T]) handle(a answer) error {
 			return err
 		}
 	case "stop":
-		defer as.eng.die()
-	case "die":
+		as.more = false
+		if as.eng.destroy {
+			defer as.eng.die()
+		}
+	case "died":
 		defer as.eng.die()
 		as.err = ErrDead
+	case "abort":
+		as.err = ErrAborted
 	case "error":
-		var msg string
-		if err := json.Unmarshal(a.Data, &msg); err != nil {
+		if isAborted(engine.Atom(a.Code)) {
+			as.err = ErrAborted
+			break
+		}
+		perr, err := newError(a)
+		if err != nil {
 			return err
 		}
-		as.err = Error{Code: a.Code, Data: msg}
+		as.err = perr
+	case "output":
+		var data Term
+		if err := json.Unmarshal(a.Data, &data); err != nil {
+			return err
+		}
+		as.output(Output{ID: a.ID, Data: data})
+		as.pull = true
+	case "prompt":
+		var data Term
+		if err := json.Unmarshal(a.Data, &data); err != nil {
+			return err
+		}
+		as.prompt = &Prompt{ID: a.ID, Data: data}
 	}
 
 	if a.Answer != nil {
@@ -130,6 +219,9 @@ func (as *iterator[T]) handle(a answer) error {
 }
 
 func (as *iterator[T]) Next(ctx context.Context) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
 more:
 	switch {
 	case as.err != nil:
@@ -140,12 +232,33 @@ more:
 	case len(as.buf) > 0:
 		as.cur = as.pop()
 		return true
-	case as.more:
-		a, err := as.eng.send(ctx, "next")
+	case as.prompt != nil:
+		if err := as.answerPrompt(ctx); err != nil {
+			as.err = err
+			return false
+		}
+		goto more
+	case as.pull:
+		as.pull = false
+		a, err := as.eng.get(ctx, &as.stats, "pull_response", as.eng.client.jsonFormat())
 		if err != nil {
+			as.err = as.canceled(ctx, err)
+			return false
+		}
+		if err := as.handle(a); err != nil {
 			as.err = err
 			return false
 		}
+		goto more
+	case as.more && as.eng.isDead():
+		as.err = ErrDead
+		return false
+	case as.more:
+		a, err := as.eng.send(ctx, &as.stats, "next")
+		if err != nil {
+			as.err = as.canceled(ctx, err)
+			return false
+		}
 		if err := as.handle(a); err != nil {
 			as.err = err
 			return false
@@ -158,25 +271,138 @@ more:
 	return false
 }
 
+// decodeAnswers decodes the data of a success event.
+func decodeAnswers[T any](c Client, raw json.RawMessage) ([]T, error) {
+	var data []T
+	if c.jsonFormat() != FormatJSONS {
+		err := json.Unmarshal(raw, &data)
+		return data, err
+	}
+
+	var answers []json.RawMessage
+	if err := json.Unmarshal(raw, &answers); err != nil {
+		return nil, err
+	}
+	switch out := any(&data).(type) {
+	case *[]Solution:
+		for _, answer := range answers {
+			var bindings map[string]string
+			if err := json.Unmarshal(answer, &bindings); err != nil {
+				return nil, err
+			}
+			sol := make(Solution, len(bindings))
+			for name, text := range bindings {
+				term, err := c.parseText(name, text)
+				if err != nil {
+					return nil, err
+				}
+				sol[name] = term
+			}
+			*out = append(*out, sol)
+		}
+	case *[]Term:
+		for _, answer := range answers {
+			term, err := c.decodeText(answer)
+			if err != nil {
+				return nil, err
+			}
+			*out = append(*out, term)
+		}
+	default:
+		return nil, fmt.Errorf("pengine: %s format only supports Solution and Term answers, not %T", FormatJSONS, data)
+	}
+	return data, nil
+}
+
+// decodeText decodes a json-s answer: either Prolog text, or an object of variable bindings as Prolog text,
+// which is decoded as a dictionary.
+func (c Client) decodeText(raw json.RawMessage) (Term, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return c.parseText("answer", text)
+	}
+	var bindings map[string]string
+	if err := json.Unmarshal(raw, &bindings); err != nil {
+		return Term{}, err
+	}
+	dict := make(map[string]Term, len(bindings))
+	for name, text := range bindings {
+		term, err := c.parseText(name, text)
+		if err != nil {
+			return Term{}, err
+		}
+		dict[name] = term
+	}
+	return Term{Dictionary: dict}, nil
+}
+
+// parseText parses the Prolog text of a json-s value.
+func (c Client) parseText(name, text string) (Term, error) {
+	parser := c.interpreter().Parser(strings.NewReader(text+" ."), nil)
+	t, err := parser.Term()
+	if err != nil {
+		return Term{}, fmt.Errorf("pengine: failed to parse %s: %w", name, err)
+	}
+	term, err := termOf(t)
+	if err != nil {
+		return Term{}, fmt.Errorf("pengine: failed to convert %s: %w", name, err)
+	}
+	return term, nil
+}
+
+// canceled aborts the running query if ctx was canceled and the client is configured to do so.
+func (as *iterator[T]) canceled(ctx context.Context, err error) error {
+	if ctx.Err() == nil || !as.eng.client.AbortOnCancel {
+		return err
+	}
+	if aerr := as.eng.Abort(context.Background()); aerr != nil {
+		return fmt.Errorf("%w (abort failed: %v)", err, aerr)
+	}
+	return err
+}
+
 // Current returns the current Solution.
 func (as *iterator[T]) Current() T {
+	as.mu.Lock()
+	defer as.mu.Unlock()
 	return as.cur
 }
 
 // Close stops this query. It is not necessary to call this if all results have been iterated through.
 func (as *iterator[T]) Close() error {
-	if as == nil || as.eng == nil || as.eng.dead {
+	if as == nil || as.eng == nil {
 		return nil
 	}
-	a, err := as.eng.send(context.Background(), "stop")
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
+	if as.eng.isDead() || !as.running() {
+		return nil
+	}
+	a, err := as.eng.send(context.Background(), &as.stats, "stop")
 	if err != nil {
 		return err
 	}
 	return as.handle(a)
 }
 
+// running returns true if this query is still running on the server.
+func (as *iterator[T]) running() bool {
+	return as.more || as.pull || as.prompt != nil
+}
+
+// settle releases the engine once this query is finished.
+func (as *iterator[T]) settle() {
+	if !as.running() || (as.err != nil && as.err != ErrPrompt) {
+		as.stats.finish()
+		as.eng.release(as.self)
+	}
+}
+
 // Error returns an error encountered by this query, if any.
 func (as *iterator[T]) Err() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
 	if as.err == nil && as.bad > 0 && as.good == 0 {
 		return ErrFailed
 	}
@@ -185,11 +411,72 @@ func (as *iterator[T]) Err() error {
 
 // Cumulative returns the cumulative time taken by this query, as reported by pengines.
 func (as *iterator[T]) Cumulative() time.Duration {
+	as.mu.Lock()
+	defer as.mu.Unlock()
 	return time.Duration(float64(time.Second) * as.cum)
 }
 
+// Stats returns statistics about this query.
+func (as *iterator[T]) Stats() Stats {
+	return as.stats.snapshot()
+}
+
+func (as *iterator[T]) queryStats() *queryStats {
+	return &as.stats
+}
+
 func (as *iterator[T]) pop() T {
 	data := as.buf[0]
 	as.buf = as.buf[1:]
 	return data
 }
+
+// converted adapts Answers[S] into Answers[T] by converting each answer.
+// If a conversion fails, the query is closed and the error is returned by Err.
+type converted[S, T any] struct {
+	Answers[S]
+	convert func(S) (T, error)
+
+	mu  sync.Mutex
+	cur T
+	err error
+}
+
+func convert[S, T any](as Answers[S], fn func(S) (T, error)) *converted[S, T] {
+	return &converted[S, T]{Answers: as, convert: fn}
+}
+
+func (as *converted[S, T]) Next(ctx context.Context) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if as.err != nil || !as.Answers.Next(ctx) {
+		return false
+	}
+	cur, err := as.convert(as.Answers.Current())
+	if err != nil {
+		as.err = err
+		as.Answers.Close()
+		return false
+	}
+	as.cur = cur
+	return true
+}
+
+func (as *converted[S, T]) Current() T {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.cur
+}
+
+func (as *converted[S, T]) Err() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if as.err != nil {
+		return as.err
+	}
+	return as.Answers.Err()
+}
+
+func (as *converted[S, T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return all[T](ctx, as)
+}
@@ -0,0 +1,278 @@
+package pengine
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to requests sent to a pengines server.
+// Set Client.Auth to use one.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// BasicAuth authenticates using HTTP basic authentication,
+// as used by SWI-Prolog's http_authenticate/3.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator.
+func (auth BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(auth.Username, auth.Password)
+	return nil
+}
+
+// BearerAuth authenticates using a bearer token in the Authorization header.
+// If the server responds with 401 Unauthorized and Source has an Invalidate method
+// (such as RefreshingToken), the token is invalidated and the request is retried once with a fresh token.
+type BearerAuth struct {
+	Source TokenSource
+}
+
+// Authenticate implements Authenticator.
+func (auth BearerAuth) Authenticate(req *http.Request) error {
+	token, err := auth.Source.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (auth BearerAuth) reauthenticate(*http.Response) bool {
+	if src, ok := auth.Source.(interface{ Invalidate() }); ok {
+		src.Invalidate()
+		return true
+	}
+	return false
+}
+
+// DigestAuth authenticates using HTTP digest authentication (RFC 7616),
+// as used by SWI-Prolog's http_authenticate/3 with a digest password file.
+// The first request is sent without credentials, and is retried once the server responds with
+// a 401 Unauthorized challenge. The challenge is reused for later requests until the server rejects it.
+// MD5 and SHA-256 are supported, with qop=auth or without qop.
+// It is safe for concurrent use, and must be used as a pointer:
+//
+//	client.Auth = &pengine.DigestAuth{Username: "alice", Password: "hunter2"}
+type DigestAuth struct {
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	challenge map[string]string // parameters of the last challenge
+	count     int               // nonce count
+}
+
+// Authenticate implements Authenticator.
+// It does nothing until the server has sent a challenge.
+func (auth *DigestAuth) Authenticate(req *http.Request) error {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if auth.challenge == nil {
+		return nil
+	}
+	hash := digestHash(auth.challenge["algorithm"])
+	if hash == nil {
+		return fmt.Errorf("pengine: unsupported digest algorithm: %s", auth.challenge["algorithm"])
+	}
+
+	realm, nonce, uri := auth.challenge["realm"], auth.challenge["nonce"], req.URL.RequestURI()
+	ha1 := hash(auth.Username + ":" + realm + ":" + auth.Password)
+	ha2 := hash(req.Method + ":" + uri)
+
+	params := []string{
+		"username=" + quoteParam(auth.Username),
+		"realm=" + quoteParam(realm),
+		"nonce=" + quoteParam(nonce),
+		"uri=" + quoteParam(uri),
+	}
+	if alg := auth.challenge["algorithm"]; alg != "" {
+		params = append(params, "algorithm="+alg)
+	}
+	if hasToken(auth.challenge["qop"], "auth") {
+		auth.count++
+		nc := fmt.Sprintf("%08x", auth.count)
+		cnonce := make([]byte, 16)
+		if _, err := rand.Read(cnonce); err != nil {
+			return err
+		}
+		cnonceHex := hex.EncodeToString(cnonce)
+		response := hash(ha1 + ":" + nonce + ":" + nc + ":" + cnonceHex + ":auth:" + ha2)
+		params = append(params, "response="+quoteParam(response), "qop=auth", "nc="+nc, "cnonce="+quoteParam(cnonceHex))
+	} else {
+		params = append(params, "response="+quoteParam(hash(ha1+":"+nonce+":"+ha2)))
+	}
+	if opaque, ok := auth.challenge["opaque"]; ok {
+		params = append(params, "opaque="+quoteParam(opaque))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+	return nil
+}
+
+// reauthenticate takes the digest challenge from a 401 response,
+// returning true if the request should be retried with it.
+func (auth *DigestAuth) reauthenticate(resp *http.Response) bool {
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		challenge := parseAuthParams(rest)
+		if challenge["nonce"] == "" || digestHash(challenge["algorithm"]) == nil {
+			return false
+		}
+		auth.mu.Lock()
+		auth.challenge = challenge
+		auth.count = 0
+		auth.mu.Unlock()
+		return true
+	}
+	return false
+}
+
+// digestHash returns the hex-encoded hash function for a digest algorithm, or nil if it is unsupported.
+func digestHash(algorithm string) func(string) string {
+	var h func() hash.Hash
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return nil
+	}
+	return func(s string) string {
+		sum := h()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+}
+
+// parseAuthParams parses the comma-separated key=value parameters of a WWW-Authenticate challenge.
+// Keys are lowercased, and values may be quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " \t,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end == -1 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+	return params
+}
+
+// quoteParam returns s as a quoted authentication parameter value.
+func quoteParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// hasToken returns true if the comma-separated list contains token.
+func hasToken(list, token string) bool {
+	for _, t := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenSource provides bearer tokens for BearerAuth.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token.
+type StaticToken string
+
+// Token implements TokenSource.
+func (token StaticToken) Token(context.Context) (string, error) {
+	return string(token), nil
+}
+
+// RefreshingToken is a TokenSource that caches a token until it expires, then fetches a new one.
+// It is safe for concurrent use.
+type RefreshingToken struct {
+	fetch func(ctx context.Context) (token string, expiry time.Time, err error)
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewRefreshingToken returns a TokenSource that calls fetch to obtain a token and its expiry time.
+// A zero expiry means the token is valid until invalidated.
+func NewRefreshingToken(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) *RefreshingToken {
+	return &RefreshingToken{fetch: fetch}
+}
+
+// Token implements TokenSource, fetching a new token if the cached one has expired.
+func (rt *RefreshingToken) Token(ctx context.Context) (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.token != "" && (rt.expiry.IsZero() || time.Now().Before(rt.expiry)) {
+		return rt.token, nil
+	}
+	token, expiry, err := rt.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	rt.token, rt.expiry = token, expiry
+	return token, nil
+}
+
+// Invalidate discards the cached token, so that the next call to Token fetches a new one.
+func (rt *RefreshingToken) Invalidate() {
+	rt.mu.Lock()
+	rt.token = ""
+	rt.mu.Unlock()
+}
+
+// HeaderFunc is an Authenticator that sets the headers it returns on each request.
+// Use it for custom schemes such as API keys or headers expected by an authenticating proxy.
+type HeaderFunc func(ctx context.Context) (http.Header, error)
+
+// Authenticate implements Authenticator.
+func (fn HeaderFunc) Authenticate(req *http.Request) error {
+	header, err := fn(req.Context())
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package pengine
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestAuth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("basic", func(t *testing.T) {
+		srv := newFakeServer(t)
+		srv.authorize = func(r *http.Request) bool {
+			user, pass, ok := r.BasicAuth()
+			return ok && user == "alice" && pass == "hunter2"
+		}
+		c := srv.client()
+
+		_, err := c.Create(ctx, false)
+		var herr *HTTPError
+		if !errors.As(err, &herr) || herr.StatusCode != http.StatusUnauthorized {
+			t.Error("want 401 without credentials, got:", err)
+		}
+
+		c.Auth = BasicAuth{Username: "alice", Password: "hunter2"}
+		as, err := c.Ask(ctx, "between(1,3,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for as.Next(ctx) {
+			n++
+		}
+		if err := as.Err(); err != nil {
+			t.Error(err)
+		}
+		if n != 3 {
+			t.Error("want 3 answers, got:", n)
+		}
+	})
+
+	t.Run("bearer refresh", func(t *testing.T) {
+		srv := newFakeServer(t)
+		srv.authorize = func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer fresh"
+		}
+		fetched := 0
+		tokens := NewRefreshingToken(func(context.Context) (string, time.Time, error) {
+			fetched++
+			if fetched == 1 {
+				return "stale", time.Time{}, nil
+			}
+			return "fresh", time.Now().Add(time.Hour), nil
+		})
+		c := srv.client()
+		c.Auth = BearerAuth{Source: tokens}
+
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		if err := eng.Ping(ctx); err != nil {
+			t.Error(err)
+		}
+		if fetched != 2 {
+			t.Error("want 2 token fetches, got:", fetched)
+		}
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		srv := newFakeServer(t)
+		srv.challenge = `Digest realm="pengines", nonce="abc,123", qop="auth", opaque="xyz"`
+		md5hex := func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		var authorized int
+		srv.authorize = func(r *http.Request) bool {
+			scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if scheme != "Digest" {
+				return false
+			}
+			p := parseAuthParams(rest)
+			ha1 := md5hex("alice:pengines:hunter2")
+			ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+			want := md5hex(ha1 + ":abc,123:" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+			ok := p["username"] == "alice" && p["uri"] == r.URL.RequestURI() && p["opaque"] == "xyz" && p["response"] == want
+			if ok {
+				authorized++
+			}
+			return ok
+		}
+		c := srv.client()
+		c.Auth = &DigestAuth{Username: "alice", Password: "hunter2"}
+
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := srv.requestCount()
+		if err := eng.Ping(ctx); err != nil {
+			t.Error(err)
+		}
+		if n := srv.requestCount() - before; n != 1 {
+			t.Error("challenge should be reused, but got requests:", n)
+		}
+		if err := eng.Close(); err != nil {
+			t.Error(err)
+		}
+		if authorized != 3 {
+			t.Error("want 3 authorized requests, got:", authorized)
+		}
+
+		c.Auth = &DigestAuth{Username: "alice", Password: "wrong"}
+		_, err = c.Create(ctx, false)
+		var herr *HTTPError
+		if !errors.As(err, &herr) || herr.StatusCode != http.StatusUnauthorized {
+			t.Error("want 401 with a wrong password, got:", err)
+		}
+	})
+
+	t.Run("header func", func(t *testing.T) {
+		srv := newFakeServer(t)
+		srv.authorize = func(r *http.Request) bool {
+			return r.Header.Get("X-Api-Key") == "secret"
+		}
+		c := srv.client()
+		c.Auth = HeaderFunc(func(context.Context) (http.Header, error) {
+			return http.Header{"X-Api-Key": []string{"secret"}}, nil
+		})
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := eng.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestAuthOption(t *testing.T) {
+	auth, err := authOption(engine.Atom("basic").Apply(engine.Atom("user"), engine.Atom("pass")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BasicAuth{Username: "user", Password: "pass"}); auth != want {
+		t.Error("bad basic auth. want:", want, "got:", auth)
+	}
+
+	auth, err = authOption(engine.Atom("bearer").Apply(engine.Atom("tok")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BearerAuth{Source: StaticToken("tok")}); auth != want {
+		t.Error("bad bearer auth. want:", want, "got:", auth)
+	}
+
+	auth, err = authOption(engine.Atom("digest").Apply(engine.Atom("user"), engine.Atom("pass")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest, ok := auth.(*DigestAuth); !ok || digest.Username != "user" || digest.Password != "pass" {
+		t.Error("bad digest auth:", auth)
+	}
+
+	v := engine.NewVariable()
+	env := engine.NewEnv().Bind(v, engine.Atom("oops"))
+	_, err = authOption(v, env)
+	var ex engine.Exception
+	if !errors.As(err, &ex) {
+		t.Fatal("want domain error, got:", err)
+	}
+	if want := "error(domain_error(authorization,oops),"; !strings.HasPrefix(ex.Error(), want) {
+		t.Error("want domain error with the resolved term, got:", ex)
+	}
+}
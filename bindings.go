@@ -0,0 +1,87 @@
+package pengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// binding is the value of a named variable in an answer.
+type binding struct {
+	name  string
+	value engine.Term
+}
+
+// parseQuery parses query, returning it as a term along with its named variables in order of appearance.
+// Variables starting with an underscore are omitted.
+func (c Client) parseQuery(query string) (engine.Term, []engine.ParsedVariable, error) {
+	var vars []engine.ParsedVariable
+	parser := c.interpreter().Parser(strings.NewReader(query+"\n."), &vars)
+	t, err := parser.Term()
+	if err != nil {
+		return nil, nil, fmt.Errorf("pengine: failed to parse query: %w", err)
+	}
+	named := vars[:0]
+	for _, v := range vars {
+		if strings.HasPrefix(string(v.Name), "_") {
+			continue
+		}
+		named = append(named, v)
+	}
+	return t, named, nil
+}
+
+// bindingsTemplate returns a template of Name=Var pairs for the variables of query, such as ['X'=X,'Y'=Y].
+func (c Client) bindingsTemplate(query string) (string, error) {
+	_, vars, err := c.parseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteRune('[')
+	for i, v := range vars {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(escapeAtom(string(v.Name)))
+		sb.WriteRune('=')
+		sb.WriteString(string(v.Name))
+	}
+	sb.WriteRune(']')
+	return sb.String(), nil
+}
+
+// bindingsOf converts an answer to a bindings template into bindings.
+func bindingsOf(answer engine.Term) ([]binding, error) {
+	var bindings []binding
+	iter := engine.ListIterator{List: answer}
+	for iter.Next() {
+		pair, ok := iter.Current().(engine.Compound)
+		if !ok || pair.Functor() != "=" || pair.Arity() != 2 {
+			return nil, fmt.Errorf("pengine: unexpected binding: %s", stringify(iter.Current()))
+		}
+		name, ok := pair.Arg(0).(engine.Atom)
+		if !ok {
+			return nil, fmt.Errorf("pengine: unexpected binding name: %s", stringify(pair.Arg(0)))
+		}
+		bindings = append(bindings, binding{name: string(name), value: pair.Arg(1)})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("pengine: unexpected bindings: %w", err)
+	}
+	return bindings, nil
+}
+
+// bindingsMap converts an answer to a bindings template into a map of variable names to values.
+func bindingsMap(answer engine.Term) (map[string]engine.Term, error) {
+	bindings, err := bindingsOf(answer)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]engine.Term, len(bindings))
+	for _, b := range bindings {
+		m[b.name] = b.value
+	}
+	return m, nil
+}
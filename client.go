@@ -3,6 +3,7 @@ package pengine
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,6 +11,17 @@ import (
 	"github.com/ichiban/prolog"
 )
 
+// JSON formats supported by Client.JSONFormat.
+const (
+	// FormatJSON is the default JSON format.
+	// It is lossy: atoms, strings, and variables are indistinguishable.
+	FormatJSON = "json"
+	// FormatJSONS is the json-s format, which encodes each variable binding as Prolog text.
+	// Bindings are parsed with Client.Interpreter, distinguishing atoms, strings, and variables.
+	// This only supports Solution and Term answers, such as those of Client.Ask and Client.AskTemplate.
+	FormatJSONS = "json-s"
+)
+
 // Client is a Pengines endpoint.
 type Client struct {
 	// URL of the pengines server, required.
@@ -19,6 +31,9 @@ type Client struct {
 	// Chunk is the number of query results to accumulate in one response. 1 by default.
 	Chunk int
 
+	// JSONFormat is the format used by JSON queries: FormatJSON (default) or FormatJSONS.
+	JSONFormat string
+
 	// SourceText is Prolog source code to load (optional).
 	SourceText string
 	// SourceURL specifies a URL of Prolog source for the pengine to load (optional).
@@ -33,15 +48,45 @@ type Client struct {
 	// If nil, a default interpreter will be used.
 	Interpreter *prolog.Interpreter
 
-	// If true, prints debug logs.
+	// OnOutput is called for each message sent by pengine_output/1 (optional).
+	// Use WithOutput to handle output for a specific query.
+	OnOutput func(Output)
+	// OnPrompt replies to prompts sent by pengine_input/2 (optional).
+	// Use WithPrompt to handle prompts for a specific query.
+	// If no handler is set, queries will stop with ErrPrompt when prompted; see Engine.Respond.
+	OnPrompt PromptHandler
+
+	// Auth adds credentials to every request (optional).
+	// See BasicAuth, DigestAuth, BearerAuth, and HeaderFunc.
+	Auth Authenticator
+
+	// Middleware is called around every request sent to the server (optional).
+	Middleware []Middleware
+
+	// Retry is the policy for retrying idempotent requests that failed because of transient errors (optional).
+	// If nil, requests are not retried.
+	Retry *RetryPolicy
+
+	// AbortOnCancel, if true, aborts the running query (see Engine.Abort)
+	// when the context given to Answers.Next is canceled during a request.
+	// Otherwise, the request is abandoned and the query keeps running on the server.
+	AbortOnCancel bool
+
+	// Logger receives structured logs of requests and events (optional).
+	// Requests and events are logged at the Debug level, and failed requests at the Warn level.
+	Logger *slog.Logger
+	// Debug, if true, also logs request and response bodies, truncated to MaxLogBody bytes,
+	// with SourceText redacted. If Logger is nil, debug logs are written to standard error.
 	Debug bool
+	// MaxLogBody is the maximum number of bytes of a body to log. 1024 by default.
+	MaxLogBody int
 }
 
 // Create creates a new pengine. Call Engine's Ask method to query it.
 // If destroy is true, the pengine will be automatically destroyed when a query completes.
 // If destroy is false, it is the caller's responsibility to destroy the pengine with Engine.Close.
 func (c Client) Create(ctx context.Context, destroy bool) (*Engine, error) {
-	eng, answer, err := c.create(ctx, "", destroy)
+	eng, answer, err := c.create(ctx, "", "", destroy)
 	if err != nil {
 		return nil, err
 	}
@@ -49,16 +94,32 @@ func (c Client) Create(ctx context.Context, destroy bool) (*Engine, error) {
 	return eng, err
 }
 
-// Ask creates a new engine with the given initial query and executes it, returning the answers iterator.
-func (c Client) Ask(ctx context.Context, query string) (Answers[Solution], error) {
-	eng, answer, err := c.create(ctx, query, true)
+// CreateProlog creates a new pengine using the Prolog format. Call Engine's AskProlog method to query it.
+// This is useful for running several queries against the same SourceText without uploading it each time.
+// If destroy is true, the pengine will be automatically destroyed when a query completes.
+// If destroy is false, it is the caller's responsibility to destroy the pengine with Engine.Close.
+func (c Client) CreateProlog(ctx context.Context, destroy bool) (*Engine, error) {
+	as, err := c.createProlog(ctx, "", "", destroy)
 	if err != nil {
 		return nil, err
 	}
-	return newIterator[Solution](eng, answer)
+	return as.eng, nil
+}
+
+// Ask creates a new engine with the given initial query and executes it, returning the answers iterator.
+// See the Ask function for placeholders.
+func (c Client) Ask(ctx context.Context, query string, args ...any) (Answers[Solution], error) {
+	return Ask[Solution](ctx, c, query, args...)
+}
+
+// AskTemplate creates a new engine with the given initial query and executes it,
+// returning an iterator of the given template instantiated by each answer.
+// See the AskTemplate function for details.
+func (c Client) AskTemplate(ctx context.Context, query, template string, args ...any) (Answers[Term], error) {
+	return AskTemplate[Term](ctx, c, query, template, args...)
 }
 
-func (c Client) create(ctx context.Context, query string, destroy bool) (*Engine, answer, error) {
+func (c Client) create(ctx context.Context, query, template string, destroy bool) (*Engine, answer, error) {
 	if c.URL == "" {
 		return nil, answer{}, fmt.Errorf("pengine: Server URL not set")
 	}
@@ -66,21 +127,41 @@ func (c Client) create(ctx context.Context, query string, destroy bool) (*Engine
 	eng := &Engine{
 		client:  c,
 		destroy: destroy,
-		debug:   c.Debug,
+		log:     c.logger(),
 	}
-	opts := c.options("json")
+	opts := c.options(c.jsonFormat())
 	if query != "" {
 		opts.Ask = query
+		opts.Template = template
+		eng.pending = new(queryStats)
 	}
 	opts.Destroy = destroy
 
-	evt, err := eng.post(ctx, "create", opts)
+	var evt answer
+	err := c.retryCreate(ctx, query, func() (err error) {
+		evt, err = eng.post(ctx, eng.pending, "create", opts)
+		return
+	})
 	if err != nil {
 		return nil, evt, fmt.Errorf("pengine create error: %w", err)
 	}
 	return eng, evt, nil
 }
 
+func (c Client) jsonFormat() string {
+	if c.JSONFormat != "" {
+		return c.JSONFormat
+	}
+	return FormatJSON
+}
+
+func (c Client) interpreter() *prolog.Interpreter {
+	if c.Interpreter != nil {
+		return c.Interpreter
+	}
+	return defaultInterpreter
+}
+
 func (c Client) client() *http.Client {
 	if c.HTTP != nil {
 		return c.HTTP
@@ -109,6 +190,16 @@ func (c Client) options(format string) options {
 	}
 }
 
+// askOptions returns the options for asking an existing pengine.
+// Create-only options such as the source and application are left out,
+// so the source isn't uploaded again with every query.
+func (c Client) askOptions() options {
+	return options{
+		Format: "prolog",
+		Chunk:  c.Chunk,
+	}
+}
+
 func (opts options) String() string {
 	var sb strings.Builder
 	first := true
@@ -0,0 +1,105 @@
+package pengine
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestEngineConcurrent(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	eng, err := srv.client().Create(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as, err := eng.Ask(ctx, "between(1,50,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := eng.Ask(ctx, "between(1,2,X)"); err != ErrBusy {
+		t.Error("want:", ErrBusy, "got:", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := eng.Ping(ctx); err != nil {
+				t.Error(err)
+				return
+			}
+			_ = eng.ID()
+			_ = as.Cumulative()
+		}
+	}()
+
+	n := 0
+	for as.Next(ctx) {
+		n++
+	}
+	close(done)
+	wg.Wait()
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 50 {
+		t.Error("answer len mismatch. want: 50 got:", n)
+	}
+
+	// previous query finished, so asking again is OK
+	as, err = eng.Ask(ctx, "between(1,2,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := as.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := eng.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Ping(ctx); err != ErrDead {
+		t.Error("want:", ErrDead, "got:", err)
+	}
+}
+
+func TestAnswersConcurrentClose(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	as, err := srv.client().Ask(ctx, "between(1,1000,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for as.Next(ctx) {
+			_ = as.Current()
+		}
+	}()
+	if err := as.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+
+	if err := as.Err(); err != nil {
+		t.Error(err)
+	}
+	if n := srv.alive(); n != 0 {
+		t.Error("pengines left alive:", n)
+	}
+}
@@ -0,0 +1,301 @@
+package pengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// ErrorKind is the kind of an ISO error: the name of the formal term of error(Formal, Context).
+// It implements error so that errors can be classified with errors.Is:
+//
+//	if errors.Is(err, pengine.KindExistence) {
+//		// ...
+//	}
+type ErrorKind string
+
+// ISO error kinds.
+const (
+	KindInstantiation  ErrorKind = "instantiation_error"
+	KindType           ErrorKind = "type_error"
+	KindDomain         ErrorKind = "domain_error"
+	KindExistence      ErrorKind = "existence_error"
+	KindPermission     ErrorKind = "permission_error"
+	KindRepresentation ErrorKind = "representation_error"
+	KindEvaluation     ErrorKind = "evaluation_error"
+	KindResource       ErrorKind = "resource_error"
+	KindSyntax         ErrorKind = "syntax_error"
+)
+
+// Error implements the error interface.
+func (k ErrorKind) Error() string {
+	return "pengine: " + string(k)
+}
+
+// Error is an error from the pengines API, sent as a JSON-format error event.
+// Use errors.As to obtain it, or errors.Is with an ErrorKind to classify it.
+type Error struct {
+	// Code is the kind of error: the name of the formal term, such as "existence_error".
+	// It is empty if the error is not an ISO error term.
+	Code string
+	// Data is the error message.
+	Data string
+	// Term is the error term, usually error(Formal, Context).
+	// If the server only sent a message, the formal term is reconstructed from the error code and arguments
+	// and the context is context(_, Message).
+	// It is the zero Term if the server sent neither a code nor a term.
+	Term Term
+}
+
+// Error implements the error interface.
+func (err Error) Error() string {
+	return fmt.Sprintf("pengine: %s: %s", err.Code, err.Data)
+}
+
+// Kind returns the kind of this error.
+func (err Error) Kind() ErrorKind {
+	return ErrorKind(err.Code)
+}
+
+// Is returns true if target is this error's kind.
+func (err Error) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind != "" && kind == err.Kind()
+}
+
+// Formal returns the formal term of this error, such as existence_error(procedure, foo/0).
+// If the error term isn't of the form error(Formal, Context), the whole term is returned.
+func (err Error) Formal() Term {
+	if c := err.Term.Compound; c != nil && c.Functor == "error" && len(c.Args) == 2 {
+		return c.Args[0]
+	}
+	return err.Term
+}
+
+// Context returns the context term of this error, if any.
+func (err Error) Context() Term {
+	if c := err.Term.Compound; c != nil && c.Functor == "error" && len(c.Args) == 2 {
+		return c.Args[1]
+	}
+	return Term{}
+}
+
+// Exception is an error thrown by a Prolog-format query.
+// Use errors.As to obtain it, or errors.Is with an ErrorKind to classify it.
+// It unwraps to an ichiban/prolog exception, so it can be caught by catch/3 when using RPC.
+type Exception struct {
+	// Ball is the thrown term, usually error(Formal, Context).
+	Ball engine.Term
+	// ID is the ID of the pengine that threw this.
+	ID string
+	// URL is the URL of the pengines server.
+	URL string
+	// Query is the query that threw this.
+	Query string
+}
+
+// Error implements the error interface.
+func (ex *Exception) Error() string {
+	return "pengine: " + stringify(ex.Ball)
+}
+
+// Kind returns the kind of this exception, or an empty string if it isn't an ISO error term.
+func (ex *Exception) Kind() ErrorKind {
+	return ErrorKind(formalName(ex.Ball))
+}
+
+// Is returns true if target is this exception's kind.
+func (ex *Exception) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind != "" && kind == ex.Kind()
+}
+
+// Unwrap returns the exception as an ichiban/prolog exception.
+func (ex *Exception) Unwrap() error {
+	return engine.NewException(ex.Ball, nil)
+}
+
+// Formal returns the formal term of this exception.
+// If the ball isn't of the form error(Formal, Context), the whole ball is returned.
+func (ex *Exception) Formal() engine.Term {
+	if c, ok := ex.Ball.(engine.Compound); ok && c.Functor() == "error" && c.Arity() == 2 {
+		return c.Arg(0)
+	}
+	return ex.Ball
+}
+
+// Context returns the context term of this exception, or nil if it doesn't have one.
+func (ex *Exception) Context() engine.Term {
+	if c, ok := ex.Ball.(engine.Compound); ok && c.Functor() == "error" && c.Arity() == 2 {
+		return c.Arg(1)
+	}
+	return nil
+}
+
+// HTTPError is returned when the server responds with an unexpected HTTP status
+// and the response body is not an error event.
+type HTTPError struct {
+	// StatusCode is the HTTP status code.
+	StatusCode int
+	// ContentType is the value of the Content-Type header.
+	ContentType string
+	// Body is the response body, truncated to 512 bytes.
+	Body string
+}
+
+// Error implements the error interface.
+func (err *HTTPError) Error() string {
+	if err.Body == "" {
+		return fmt.Sprintf("pengine: bad status: %d", err.StatusCode)
+	}
+	return fmt.Sprintf("pengine: bad status: %d: %s", err.StatusCode, err.Body)
+}
+
+// newError decodes a JSON-format error event.
+func newError(a answer) (Error, error) {
+	perr := Error{Code: a.Code}
+	var data Term
+	if len(a.Data) > 0 {
+		if err := json.Unmarshal(a.Data, &data); err != nil {
+			return perr, err
+		}
+	}
+
+	if data.Atom != nil {
+		perr.Data = *data.Atom
+		if perr.Code == "" {
+			return perr, nil
+		}
+		formal, err := formalOf(a)
+		if err != nil {
+			return perr, err
+		}
+		perr.Term = compoundTerm("error", formal, compoundTerm("context", variableTerm("_"), data))
+		return perr, nil
+	}
+
+	// structured error term
+	perr.Term = data
+	t, err := data.prolog()
+	if err != nil {
+		return perr, err
+	}
+	if t != nil {
+		perr.Data = stringify(t)
+		if perr.Code == "" {
+			perr.Code = formalName(t)
+		}
+	}
+	return perr, nil
+}
+
+// formalOf reconstructs the formal term of an error event from its code and arguments.
+func formalOf(a answer) (Term, error) {
+	var args []Term
+	for _, raw := range []json.RawMessage{a.Arg1, a.Arg2} {
+		if len(raw) == 0 {
+			break
+		}
+		var arg Term
+		if err := json.Unmarshal(raw, &arg); err != nil {
+			return Term{}, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		code := a.Code
+		return Term{Atom: &code}, nil
+	}
+	return compoundTerm(a.Code, args...), nil
+}
+
+func compoundTerm(functor string, args ...Term) Term {
+	return Term{Compound: &Compound{Functor: functor, Args: args}}
+}
+
+func variableTerm(name string) Term {
+	return Term{Variable: &name}
+}
+
+// formalName returns the name of the formal term of an error(Formal, Context) term.
+func formalName(t engine.Term) string {
+	c, ok := t.(engine.Compound)
+	if !ok || c.Functor() != "error" || c.Arity() != 2 {
+		return ""
+	}
+	switch formal := c.Arg(0).(type) {
+	case engine.Atom:
+		return string(formal)
+	case engine.Compound:
+		return string(formal.Functor())
+	}
+	return ""
+}
+
+// errorKind returns the kind of a pengine error or Prolog exception.
+func errorKind(err error) ErrorKind {
+	var perr Error
+	if errors.As(err, &perr) {
+		return perr.Kind()
+	}
+	var pex *Exception
+	if errors.As(err, &pex) {
+		return pex.Kind()
+	}
+	var ex engine.Exception
+	if errors.As(err, &ex) {
+		return ErrorKind(formalName(ex.Term()))
+	}
+	return ""
+}
+
+// IsExistenceError returns true if err is an existence_error, such as calling an unknown procedure.
+func IsExistenceError(err error) bool {
+	return errorKind(err) == KindExistence
+}
+
+// IsPermissionError returns true if err is a permission_error, such as a sandbox violation.
+func IsPermissionError(err error) bool {
+	return errorKind(err) == KindPermission
+}
+
+// IsTypeError returns true if err is a type_error.
+func IsTypeError(err error) bool {
+	return errorKind(err) == KindType
+}
+
+// IsTimeLimit returns true if err is caused by the query exceeding the server's time limit.
+func IsTimeLimit(err error) bool {
+	if errorKind(err) == "time_limit_exceeded" {
+		return true
+	}
+	var perr Error
+	if errors.As(err, &perr) {
+		if perr.Data == "Time limit exceeded" {
+			return true
+		}
+		ball, _ := perr.Term.prolog()
+		return isTimeLimit(ball)
+	}
+	var pex *Exception
+	if errors.As(err, &pex) {
+		return isTimeLimit(pex.Ball)
+	}
+	var ex engine.Exception
+	if errors.As(err, &ex) {
+		return isTimeLimit(ex.Term())
+	}
+	return false
+}
+
+func isTimeLimit(ball engine.Term) bool {
+	switch ball := ball.(type) {
+	case engine.Atom:
+		return ball == "time_limit_exceeded"
+	case engine.Compound:
+		return ball.Functor() == "time_limit_exceeded"
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package pengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestError(t *testing.T) {
+	t.Run("existence error", func(t *testing.T) {
+		var a answer
+		if err := json.Unmarshal([]byte(`{"event":"error","id":"x","code":"existence_error","arg1":"procedure","arg2":"foo/0","data":"Unknown procedure: foo/0"}`), &a); err != nil {
+			t.Fatal(err)
+		}
+		perr, err := newError(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perr.Data != "Unknown procedure: foo/0" {
+			t.Error("unexpected message:", perr.Data)
+		}
+		want := engine.Atom("existence_error").Apply(engine.Atom("procedure"), engine.Atom("foo/0"))
+		if got := perr.Formal().Prolog(); !reflect.DeepEqual(want, got) {
+			t.Error("bad formal. want:", want, "got:", got)
+		}
+		wrapped := fmt.Errorf("wrapped: %w", perr)
+		if !IsExistenceError(wrapped) {
+			t.Error("not an existence error:", wrapped)
+		}
+		if IsTypeError(wrapped) || IsPermissionError(wrapped) || IsTimeLimit(wrapped) {
+			t.Error("misclassified:", wrapped)
+		}
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		var a answer
+		if err := json.Unmarshal([]byte(`{"event":"error","id":"x","data":{"functor":"error","args":[{"functor":"type_error","args":["integer","abc"]},"_"]}}`), &a); err != nil {
+			t.Fatal(err)
+		}
+		perr, err := newError(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perr.Code != "type_error" {
+			t.Error("want code type_error, got:", perr.Code)
+		}
+		if !IsTypeError(perr) {
+			t.Error("not a type error:", perr)
+		}
+	})
+
+	t.Run("big integer", func(t *testing.T) {
+		var a answer
+		if err := json.Unmarshal([]byte(`{"event":"error","id":"x","data":{"functor":"error","args":[{"functor":"type_error","args":["integer",123456789012345678901234567890]},"_"]}}`), &a); err != nil {
+			t.Fatal(err)
+		}
+		perr, err := newError(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "error(type_error(integer,123456789012345678901234567890),_)"; perr.Data != want {
+			t.Error("bad data. want:", want, "got:", perr.Data)
+		}
+		if !IsTypeError(perr) || IsTimeLimit(perr) {
+			t.Error("misclassified:", perr)
+		}
+	})
+
+	t.Run("time limit", func(t *testing.T) {
+		perr, err := newError(answer{Event: "error", Data: json.RawMessage(`"Time limit exceeded"`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsTimeLimit(perr) {
+			t.Error("not a time limit error:", perr)
+		}
+		if !IsTimeLimit(engine.NewException(engine.Atom("time_limit_exceeded"), nil)) {
+			t.Error("exception is not a time limit error")
+		}
+	})
+}
+
+func TestException(t *testing.T) {
+	ball := engine.Atom("error").Apply(
+		engine.Atom("existence_error").Apply(engine.Atom("procedure"), engine.Atom("/").Apply(engine.Atom("foo"), engine.Integer(0))),
+		engine.Atom("foo").Apply(engine.Integer(0)),
+	)
+	var err error = fmt.Errorf("wrapped: %w", &Exception{Ball: ball, ID: "x", Query: "foo"})
+
+	if !errors.Is(err, KindExistence) {
+		t.Error("not an existence error:", err)
+	}
+	if errors.Is(err, KindType) || IsTypeError(err) {
+		t.Error("misclassified:", err)
+	}
+	if !IsExistenceError(err) {
+		t.Error("not an existence error:", err)
+	}
+
+	var ex *Exception
+	if !errors.As(err, &ex) {
+		t.Fatal("not an Exception:", err)
+	}
+	if ex.Query != "foo" {
+		t.Error("unexpected query:", ex.Query)
+	}
+	if want := engine.Atom("procedure"); ex.Formal().(engine.Compound).Arg(0) != want {
+		t.Error("unexpected formal:", ex.Formal())
+	}
+
+	var iso engine.Exception
+	if !errors.As(err, &iso) {
+		t.Fatal("doesn't unwrap to engine.Exception:", err)
+	}
+	if !reflect.DeepEqual(ball, iso.Term()) {
+		t.Error("bad ball. want:", ball, "got:", iso.Term())
+	}
+}
@@ -0,0 +1,234 @@
+package pengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is an event sent by a pengine, received with Engine.Events.
+type Event struct {
+	// Type is the kind of event, such as "success", "failure", "error", "output", "prompt", "destroy", or "died".
+	Type string
+	// ID is the ID of the pengine that sent this event.
+	ID string
+	// Data is this event's data, if any.
+	// For success events, it is a list of solutions. For output and prompt events, it is the sent term.
+	Data Term
+	// Projection is the list of variable names for success events.
+	Projection []string
+	// More is true for success events when more answers are available.
+	More bool
+	// Time is the time taken by the query, as reported by pengines.
+	Time time.Duration
+	// Err is the error for error events.
+	// It is also set for the final event when polling stops because of an error, in which case Type is empty.
+	Err error
+}
+
+// Events long-polls this pengine for events pushed by the server, like the JavaScript client does.
+// This receives output, prompts, and answers that arrive while no request is in progress,
+// such as the remaining events of a query whose Next call was canceled.
+//
+// The returned channel is closed when ctx is canceled, the pengine dies,
+// or the pengine becomes idle after sending an answer (a success, failure, error, or abort event).
+// Polling an idle pengine will block until the server's time limit is exceeded.
+// Prompt events pause polling until Engine.Respond is called.
+//
+// If a query started with Engine.Ask is running, its events are also applied to it,
+// so it is released once it finishes and its remaining answers can still be read with Answers.Next.
+// Queries started with Engine.AskProlog can't receive events, so while one is running
+// the channel only receives an Event whose Err is ErrBusy.
+//
+// Events must not be used while another request to this pengine is in progress, such as Answers.Next.
+func (e *Engine) Events(ctx context.Context) <-chan Event {
+	stream := &eventStream{
+		input: make(chan string, 1),
+	}
+	e.mu.Lock()
+	_, ok := e.query.(deliverer)
+	busy := e.query != nil && !ok
+	if !busy {
+		e.stream = stream
+	}
+	e.mu.Unlock()
+
+	if busy {
+		ch := make(chan Event, 1)
+		ch <- Event{Err: ErrBusy}
+		close(ch)
+		return ch
+	}
+	ch := make(chan Event)
+	go e.poll(ctx, ch, stream)
+	return ch
+}
+
+// deliverer is implemented by queries that can receive events from Engine.Events.
+type deliverer interface {
+	// deliver handles an event received by the poller on behalf of this query.
+	deliver(a answer) error
+}
+
+// eventStream is the state of an Engine.Events poller.
+type eventStream struct {
+	mu       sync.Mutex
+	prompted bool
+	input    chan string
+}
+
+// respond hands input to the poller if it is waiting for a reply to a prompt.
+func (s *eventStream) respond(input string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.prompted {
+		return false
+	}
+	s.prompted = false
+	s.input <- input
+	return true
+}
+
+func (s *eventStream) prompt() {
+	s.mu.Lock()
+	s.prompted = true
+	s.mu.Unlock()
+}
+
+// stop stops accepting input, so that prompts are answered another way once the poller exits.
+func (s *eventStream) stop() {
+	s.mu.Lock()
+	s.prompted = false
+	s.mu.Unlock()
+}
+
+func (e *Engine) poll(ctx context.Context, ch chan<- Event, stream *eventStream) {
+	defer close(ch)
+	defer func() {
+		stream.stop()
+		e.mu.Lock()
+		if e.stream == stream {
+			e.stream = nil
+		}
+		e.mu.Unlock()
+	}()
+
+	// requests made by the poller belong to the running query, if any
+	stats := e.queryStats()
+	next := func() (answer, error) {
+		return e.get(ctx, stats, "pull_response", e.client.jsonFormat())
+	}
+	for {
+		a, err := next()
+		if err != nil {
+			select {
+			case ch <- Event{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if err := e.route(a); err != nil {
+			select {
+			case ch <- Event{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		events := flatten(a, nil)
+		for _, evt := range events {
+			if evt.Event == "prompt" {
+				stream.prompt()
+			}
+			select {
+			case ch <- newEvent(e.client, evt):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if e.isDead() || len(events) == 0 {
+			return
+		}
+		switch events[len(events)-1].Event {
+		case "create", "output":
+			next = func() (answer, error) {
+				return e.get(ctx, stats, "pull_response", e.client.jsonFormat())
+			}
+		case "prompt":
+			select {
+			case input := <-stream.input:
+				next = func() (answer, error) {
+					return e.send(ctx, stats, input)
+				}
+			case <-ctx.Done():
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// route applies a to the running query, if any, or else to this pengine.
+func (e *Engine) route(a answer) error {
+	e.mu.Lock()
+	q, _ := e.query.(deliverer)
+	e.mu.Unlock()
+	if q != nil {
+		return q.deliver(a)
+	}
+	return e.handle(a)
+}
+
+// flatten returns a and the events nested inside it, in order.
+func flatten(a answer, events []answer) []answer {
+	events = append(events, a)
+	if a.Event == "destroy" && len(a.Data) > 0 {
+		var child answer
+		if err := json.Unmarshal(a.Data, &child); err == nil {
+			events = flatten(child, events)
+		}
+	}
+	if a.Answer != nil {
+		events = flatten(*a.Answer, events)
+	}
+	return events
+}
+
+func newEvent(c Client, a answer) Event {
+	evt := Event{
+		Type:       a.Event,
+		ID:         a.ID,
+		Projection: a.Projection,
+		More:       a.More,
+		Time:       time.Duration(float64(time.Second) * a.Time),
+	}
+	if a.Event == "success" && len(a.Data) > 0 {
+		answers, err := decodeAnswers[Term](c, a.Data)
+		if err != nil {
+			evt.Err = fmt.Errorf("pengine: failed to decode %s event: %w", a.Event, err)
+			return evt
+		}
+		evt.Data = Term{List: answers}
+	} else if len(a.Data) > 0 && a.Event != "destroy" {
+		if err := json.Unmarshal(a.Data, &evt.Data); err != nil {
+			evt.Err = fmt.Errorf("pengine: failed to decode %s event: %w", a.Event, err)
+			return evt
+		}
+	}
+	switch a.Event {
+	case "error":
+		perr, err := newError(a)
+		if err != nil {
+			evt.Err = err
+			break
+		}
+		evt.Err = perr
+	case "abort":
+		evt.Err = ErrAborted
+	}
+	return evt
+}
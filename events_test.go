@@ -0,0 +1,89 @@
+package pengine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestEventsQuery(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	eng, err := srv.client().Create(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+
+	// the output is returned by ask, and the answer is pulled by Events
+	as, err := eng.Ask(ctx, "pengine_output(hello), between(1,1,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for evt := range eng.Events(ctx) {
+		if evt.Err != nil {
+			t.Fatal(evt.Err)
+		}
+		got = append(got, evt.Type)
+	}
+	if want := []string{"success"}; !reflect.DeepEqual(want, got) {
+		t.Error("unexpected events. want:", want, "got:", got)
+	}
+
+	// the query received the answer and was released
+	if !as.Next(ctx) {
+		t.Fatal("no answer:", as.Err())
+	}
+	if x := as.Current()["X"]; x.Number == nil || *x.Number != "1" {
+		t.Error("want X = 1, got:", x)
+	}
+	as2, err := eng.Ask(ctx, "between(1,1,X)")
+	if err != nil {
+		t.Fatal("query was not released:", err)
+	}
+	as2.Close()
+}
+
+func TestEventsRespondAfterCancel(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	eng, err := srv.client().Create(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+
+	as, err := eng.Ask(ctx, "pengine_output(hello), pengine_input(continue, _), between(1,1,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evctx, cancel := context.WithCancel(ctx)
+	events := eng.Events(evctx)
+	if evt := <-events; evt.Type != "prompt" {
+		t.Fatal("want prompt, got:", evt)
+	}
+	cancel()
+	for range events {
+	}
+
+	// with the poller gone, the reply goes to the query instead of being dropped
+	if err := eng.Respond(ctx, engine.Atom("yes")); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	last := srv.sends[len(srv.sends)-1]
+	srv.mu.Unlock()
+	if !strings.HasPrefix(last, "input(") {
+		t.Error("reply was not sent, last send:", last)
+	}
+	if !as.Next(ctx) {
+		t.Fatal("no answer:", as.Err())
+	}
+	if err := eng.Respond(ctx, engine.Atom("yes")); err == nil {
+		t.Error("want error responding without a pending prompt")
+	}
+}
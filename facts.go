@@ -0,0 +1,147 @@
+package pengine
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// FactsOptions controls the declarations written by Facts.
+// Declarations use the functional form, such as :- dynamic(edge/2).
+type FactsOptions struct {
+	// Dynamic, if true, declares the facts' predicates with :- dynamic.
+	Dynamic bool
+	// Discontiguous, if true, declares the facts' predicates with :- discontiguous.
+	Discontiguous bool
+}
+
+// Facts renders each element of facts as a Prolog clause, converted with Marshal, such as:
+//
+//	type edge struct {
+//		_    struct{} `pengine:"edge"`
+//		From string   `pengine:",arg=2"`
+//		To   string   `pengine:",arg=1"`
+//	}
+//	src, err := Facts([]edge{{"a", "b"}}, FactsOptions{Dynamic: true})
+//	// :- dynamic(edge/2).
+//	// edge(b,a).
+//
+// Each element must convert to an atom or compound, and not to a directive, rule, or query
+// such as :-(Goal), :-(Head, Body), -->(Head, Body), or ?-(Goal).
+// Predicates are declared in order of first appearance. If facts is empty and T is a struct,
+// its predicate is still declared, so with Dynamic set, querying it fails instead of throwing an existence error.
+func Facts[T any](facts []T, opts FactsOptions) (string, error) {
+	clauses := make([]engine.Term, 0, len(facts))
+	var preds []predicateIndicator
+	seen := make(map[predicateIndicator]bool)
+	for i, fact := range facts {
+		t, err := Marshal(fact)
+		if err != nil {
+			return "", fmt.Errorf("pengine: can't marshal fact %d: %w", i, err)
+		}
+		pi, ok := indicatorOf(t)
+		if !ok {
+			return "", fmt.Errorf("pengine: fact %d is not callable: %s", i, stringify(t))
+		}
+		if pi.clause() {
+			return "", fmt.Errorf("pengine: fact %d would be read as a directive or rule: %s", i, stringify(t))
+		}
+		if !seen[pi] {
+			seen[pi] = true
+			preds = append(preds, pi)
+		}
+		clauses = append(clauses, t)
+	}
+	if len(facts) == 0 {
+		typ := reflect.TypeOf((*T)(nil)).Elem()
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.Struct && !typ.Implements(termMarshalerType) {
+			fields, err := argsOf(typ)
+			if err != nil {
+				return "", err
+			}
+			if name := functorOf(typ); name != "" {
+				preds = append(preds, predicateIndicator{name: name, arity: len(fields)})
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, pi := range preds {
+		if opts.Dynamic {
+			sb.WriteString(":- dynamic(" + pi.String() + ").\n")
+		}
+		if opts.Discontiguous {
+			sb.WriteString(":- discontiguous(" + pi.String() + ").\n")
+		}
+	}
+	for _, t := range clauses {
+		text := stringify(t)
+		sb.WriteString(text)
+		if isSymbolChar(text[len(text)-1]) {
+			// keep the end token from merging with a symbol atom, like in foo(-) .
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(".\n")
+	}
+	return sb.String(), nil
+}
+
+// AppendFacts renders facts with Facts and appends them to c.SourceText.
+//
+//	client := pengine.Client{URL: "http://localhost:4242/pengine"}
+//	if err := pengine.AppendFacts(&client, people, pengine.FactsOptions{}); err != nil {
+//		panic(err)
+//	}
+//	answers, err := client.Ask(ctx, "person(Name, Age)")
+func AppendFacts[T any](c *Client, facts []T, opts FactsOptions) error {
+	src, err := Facts(facts, opts)
+	if err != nil {
+		return err
+	}
+	if c.SourceText != "" && !strings.HasSuffix(c.SourceText, "\n") {
+		c.SourceText += "\n"
+	}
+	c.SourceText += src
+	return nil
+}
+
+// predicateIndicator is a predicate's Name/Arity.
+type predicateIndicator struct {
+	name  string
+	arity int
+}
+
+func (pi predicateIndicator) String() string {
+	name := escapeAtom(pi.name)
+	if isSymbolChar(name[0]) || name == ";" || name == "|" {
+		// operators like - need parentheses to be read as atoms
+		name = "(" + name + ")"
+	}
+	return name + "/" + strconv.Itoa(pi.arity)
+}
+
+// clause returns true if terms with this indicator are read as something other than a fact.
+func (pi predicateIndicator) clause() bool {
+	switch pi {
+	case predicateIndicator{":-", 1}, predicateIndicator{":-", 2}, predicateIndicator{"-->", 2}, predicateIndicator{"?-", 1}:
+		return true
+	}
+	return false
+}
+
+// indicatorOf returns the predicate indicator of a callable term.
+func indicatorOf(t engine.Term) (predicateIndicator, bool) {
+	switch t := t.(type) {
+	case engine.Atom:
+		return predicateIndicator{name: string(t)}, true
+	case engine.Compound:
+		return predicateIndicator{name: string(t.Functor()), arity: t.Arity()}, true
+	}
+	return predicateIndicator{}, false
+}
@@ -0,0 +1,161 @@
+package pengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ichiban/prolog"
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestFacts(t *testing.T) {
+	type edge struct {
+		_    struct{} `pengine:"edge"`
+		From string   `pengine:",arg=2"`
+		To   string   `pengine:",arg=1"`
+		Cost float64
+	}
+	type Quote struct {
+		Who  string
+		Text string `pengine:",string"`
+	}
+
+	edges := []edge{{From: "a", To: "b", Cost: 1}, {From: "it's", To: "x.\ny", Cost: 2.5}}
+	src, err := Facts(edges, FactsOptions{Dynamic: true, Discontiguous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `:- dynamic(edge/3).
+:- discontiguous(edge/3).
+edge(b,a,1.0).
+edge('x.\ny','it\'s',2.5).
+`
+	if src != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, src)
+	}
+
+	// the generated source should load and query cleanly
+	// (ichiban/prolog doesn't support discontiguous/1)
+	src, err = Facts(edges, FactsOptions{Dynamic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := prolog.New(nil, nil)
+	if err := p.Exec(src); err != nil {
+		t.Fatal(err)
+	}
+	sol := p.QuerySolution(`edge(To, 'it''s', Cost).`)
+	var got struct {
+		To   string
+		Cost float64
+	}
+	if err := sol.Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.To != "x.\ny" || got.Cost != 2.5 {
+		t.Errorf("unexpected answer: %+v", got)
+	}
+
+	src, err = Facts([]any{nothingFact{}, "-", Quote{"me", `say "hi"`}}, FactsOptions{Dynamic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `:- dynamic(nothing/0).
+:- dynamic((-)/0).
+:- dynamic(quote/2).
+nothing.
+- .
+quote(me,"say \"hi\"").
+`
+	if src != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, src)
+	}
+
+	src, err = Facts([]edge{}, FactsOptions{Dynamic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ":- dynamic(edge/3).\n"; src != want {
+		t.Errorf("want: %q, got: %q", want, src)
+	}
+
+	bad := map[string]func() error{
+		"not callable": func() error { _, err := Facts([]int{1}, FactsOptions{}); return err },
+		"directive": func() error {
+			_, err := Facts([]engine.Term{engine.Atom(":-").Apply(engine.Atom("halt"))}, FactsOptions{})
+			return err
+		},
+		"rule": func() error {
+			_, err := Facts([]engine.Term{engine.Atom(":-").Apply(engine.Atom("a"), engine.Atom("b"))}, FactsOptions{})
+			return err
+		},
+		"dcg": func() error {
+			_, err := Facts([]engine.Term{engine.Atom("-->").Apply(engine.Atom("a"), engine.Atom("b"))}, FactsOptions{})
+			return err
+		},
+		"query": func() error {
+			_, err := Facts([]engine.Term{engine.Atom("?-").Apply(engine.Atom("halt"))}, FactsOptions{})
+			return err
+		},
+		"bad arg": func() error {
+			_, err := Facts([]struct {
+				_ struct{} `pengine:"f"`
+				A int      `pengine:",arg=x"`
+			}{{A: 1}}, FactsOptions{})
+			return err
+		},
+		"duplicate arg": func() error {
+			_, err := Facts([]struct {
+				_ struct{} `pengine:"f"`
+				A int      `pengine:",arg=1"`
+				B int      `pengine:",arg=1"`
+			}{{}}, FactsOptions{})
+			return err
+		},
+		"arg out of range": func() error {
+			_, err := Facts([]struct {
+				_ struct{} `pengine:"f"`
+				A int      `pengine:",arg=2"`
+			}{}, FactsOptions{Dynamic: true})
+			return err
+		},
+		"negative arg": func() error {
+			_, err := Facts([]struct {
+				_ struct{} `pengine:"f"`
+				A int      `pengine:",arg=-1"`
+				B int
+			}{{}}, FactsOptions{})
+			return err
+		},
+	}
+	for name, fn := range bad {
+		if err := fn(); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+type nothingFact struct {
+	_ struct{} `pengine:"nothing"`
+}
+
+func TestAppendFacts(t *testing.T) {
+	srv := newFakeServer(t)
+	c := Client{URL: srv.URL, SourceText: "foo(bar)."}
+	if err := AppendFacts(&c, []string{"baz"}, FactsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "foo(bar).\nbaz.\n"; c.SourceText != want {
+		t.Errorf("want: %q, got: %q", want, c.SourceText)
+	}
+	eng, err := c.Create(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.source != c.SourceText {
+		t.Errorf("server got src_text %q, want %q", srv.source, c.SourceText)
+	}
+}
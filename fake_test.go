@@ -0,0 +1,219 @@
+package pengine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer is a minimal pengines server speaking the JSON format.
+// It only understands queries of the form between(1,N,X).
+// If the query also calls pengine_output, it sends output first and the rest must be pulled.
+// If the query also calls pengine_input, it prompts once before answering.
+type fakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	pengines map[string]*fakePengine
+	nextID   int
+	fail     int // number of upcoming requests to fail with 503
+	requests int
+
+	authorize func(*http.Request) bool // if set, requests it rejects get 401
+	challenge string                   // WWW-Authenticate header sent with 401
+	source    string                   // src_text of the last create request
+	sends     []string                 // bodies of send requests
+}
+
+type fakePengine struct {
+	destroy bool
+	chunk   int
+	next    int  // next answer
+	last    int  // last answer
+	prompt  bool // waiting for input
+	output  bool // output sent, waiting for pull_response
+}
+
+var fakeQuery = regexp.MustCompile(`between\(1,\s*\(?\s*(\d+)\s*\)?\s*,\s*X\)`)
+
+func newFakeServer(t *testing.T) *fakeServer {
+	srv := &fakeServer{
+		pengines: make(map[string]*fakePengine),
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *fakeServer) client() Client {
+	return Client{URL: srv.URL}
+}
+
+func (srv *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.requests++
+	if srv.authorize != nil && !srv.authorize(r) {
+		if srv.challenge != "" {
+			w.Header().Set("WWW-Authenticate", srv.challenge)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if srv.fail > 0 {
+		srv.fail--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	id := r.URL.Query().Get("id")
+	body, _ := io.ReadAll(r.Body)
+
+	switch r.URL.Path {
+	case "/create":
+		var opts options
+		if err := json.Unmarshal(body, &opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		srv.source = opts.SourceText
+		srv.nextID++
+		id = strconv.Itoa(srv.nextID)
+		p := &fakePengine{destroy: opts.Destroy, chunk: opts.Chunk}
+		srv.pengines[id] = p
+		evt := map[string]any{"event": "create", "id": id, "slave_limit": 3}
+		if opts.Ask != "" {
+			evt["answer"] = srv.ask(id, p, opts.Ask)
+		}
+		json.NewEncoder(w).Encode(evt)
+	case "/send":
+		p, ok := srv.pengines[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"event": "died", "id": id})
+			return
+		}
+		srv.sends = append(srv.sends, string(body))
+		cmd := strings.TrimSuffix(strings.TrimSpace(string(body)), ".")
+		cmd = strings.TrimSpace(cmd)
+		switch {
+		case strings.HasPrefix(cmd, "ask("):
+			json.NewEncoder(w).Encode(srv.ask(id, p, cmd))
+		case strings.HasPrefix(cmd, "input(") && p.prompt:
+			p.prompt = false
+			json.NewEncoder(w).Encode(srv.answer(id, p))
+		case cmd == "next":
+			json.NewEncoder(w).Encode(srv.answer(id, p))
+		case cmd == "stop":
+			p.next = p.last + 1
+			json.NewEncoder(w).Encode(srv.destroyOr(id, p, map[string]any{"event": "stop", "id": id}))
+		case cmd == "destroy":
+			delete(srv.pengines, id)
+			json.NewEncoder(w).Encode(map[string]any{"event": "destroy", "id": id})
+		default:
+			http.Error(w, "bad command: "+cmd, http.StatusBadRequest)
+		}
+	case "/pull_response":
+		p, ok := srv.pengines[id]
+		if !ok || !p.output {
+			http.Error(w, "nothing to pull", http.StatusBadRequest)
+			return
+		}
+		p.output = false
+		if p.prompt {
+			json.NewEncoder(w).Encode(map[string]any{"event": "prompt", "id": id, "data": "continue?"})
+			return
+		}
+		json.NewEncoder(w).Encode(srv.answer(id, p))
+	case "/ping":
+		if _, ok := srv.pengines[id]; !ok {
+			json.NewEncoder(w).Encode(map[string]any{"event": "died", "id": id})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"event": "ping", "id": id})
+	case "/abort":
+		json.NewEncoder(w).Encode(true)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (srv *fakeServer) ask(id string, p *fakePengine, query string) map[string]any {
+	m := fakeQuery.FindStringSubmatch(query)
+	if m == nil {
+		return srv.destroyOr(id, p, map[string]any{"event": "error", "id": id, "code": "existence_error", "data": "Unknown procedure: " + query})
+	}
+	p.next = 1
+	p.last, _ = strconv.Atoi(m[1])
+	p.prompt = strings.Contains(query, "pengine_input")
+	if strings.Contains(query, "pengine_output") {
+		p.output = true
+		return map[string]any{"event": "output", "id": id, "data": "hello"}
+	}
+	if p.prompt {
+		return map[string]any{"event": "prompt", "id": id, "data": "continue?"}
+	}
+	return srv.answer(id, p)
+}
+
+func (srv *fakeServer) answer(id string, p *fakePengine) map[string]any {
+	if p.next > p.last {
+		return srv.destroyOr(id, p, map[string]any{"event": "failure", "id": id, "time": 0.001})
+	}
+	chunk := p.chunk
+	if chunk < 1 {
+		chunk = 1
+	}
+	var data []map[string]any
+	for i := 0; i < chunk && p.next <= p.last; i++ {
+		data = append(data, map[string]any{"X": p.next})
+		p.next++
+	}
+	evt := map[string]any{
+		"event":      "success",
+		"id":         id,
+		"data":       data,
+		"projection": []string{"X"},
+		"time":       0.001,
+		"more":       p.next <= p.last,
+	}
+	if p.next > p.last {
+		return srv.destroyOr(id, p, evt)
+	}
+	return evt
+}
+
+func (srv *fakeServer) destroyOr(id string, p *fakePengine, evt map[string]any) map[string]any {
+	if !p.destroy {
+		return evt
+	}
+	delete(srv.pengines, id)
+	return map[string]any{"event": "destroy", "id": id, "data": evt}
+}
+
+func (srv *fakeServer) alive() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.pengines)
+}
+
+// failNext makes the next n requests fail with 503 Service Unavailable.
+func (srv *fakeServer) failNext(n int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.fail = n
+}
+
+func (srv *fakeServer) requestCount() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.requests
+}
@@ -1,5 +1,5 @@
 module github.com/guregu/pengine
 
-go 1.18
+go 1.23
 
 require github.com/ichiban/prolog v0.11.1
@@ -0,0 +1,111 @@
+package pengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultMaxLogBody is the default maximum number of bytes of a body to log.
+const defaultMaxLogBody = 1024
+
+// logger returns the logger to use, or nil if logging is disabled.
+// If Debug is set without a Logger, debug logs are written to standard error.
+func (c Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	if c.Debug {
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return nil
+}
+
+func (c Client) maxLogBody() int {
+	if c.MaxLogBody > 0 {
+		return c.MaxLogBody
+	}
+	return defaultMaxLogBody
+}
+
+// redact removes the source text from a request body.
+func (c Client) redact(body string) string {
+	if c.SourceText == "" {
+		return body
+	}
+	return strings.ReplaceAll(body, escapeAtom(c.SourceText), redacted(c.SourceText))
+}
+
+func redacted(src string) string {
+	return fmt.Sprintf("'<redacted %d bytes>'", len(src))
+}
+
+// truncate shortens body for logging.
+func (c Client) truncate(body string) string {
+	if max := c.maxLogBody(); len(body) > max {
+		return body[:max] + fmt.Sprintf("... (%d bytes)", len(body))
+	}
+	return body
+}
+
+// loggable returns the request body to log, or an empty string if bodies aren't logged.
+func (e *Engine) loggable(body any) string {
+	if e.log == nil || !e.client.Debug {
+		return ""
+	}
+	if opts, ok := body.(options); ok && opts.SourceText != "" {
+		opts.SourceText = redacted(opts.SourceText)
+		body = opts
+	}
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
+
+// logRequest logs a completed request.
+// Successful requests are logged at the Debug level and failed ones at the Warn level.
+func (e *Engine) logRequest(ctx context.Context, info RequestInfo, start time.Time, status int, reqBody string, respBody []byte, err error) {
+	if e.log == nil {
+		return
+	}
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("engine", info.EngineID),
+		slog.String("action", info.Action),
+		slog.String("format", info.Format),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if status != 0 {
+		attrs = append(attrs, slog.Int("status", status))
+		if status != 200 {
+			level = slog.LevelWarn
+		}
+	}
+	attrs = append(attrs, slog.Int("bytes", len(respBody)))
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if e.client.Debug {
+		if reqBody != "" {
+			attrs = append(attrs, slog.String("request", e.client.truncate(e.client.redact(reqBody))))
+		}
+		attrs = append(attrs, slog.String("response", e.client.truncate(string(respBody))))
+	}
+	e.log.LogAttrs(ctx, level, "pengine request", attrs...)
+}
+
+// logEvent logs an event received from the server at the Debug level.
+func (e *Engine) logEvent(event string, attrs ...slog.Attr) {
+	if e.log == nil {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("engine", e.ID()), slog.String("event", event)}, attrs...)
+	e.log.LogAttrs(context.Background(), slog.LevelDebug, "pengine event", attrs...)
+}
@@ -0,0 +1,67 @@
+package pengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	c := srv.client()
+	c.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c.SourceText = "secret(42).\n"
+	c.Debug = true
+	c.MaxLogBody = 64
+
+	as, err := c.Ask(ctx, "between(1,2,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for as.Next(ctx) {
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Error("source text should be redacted:", buf.String())
+	}
+
+	var requests, events int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		switch record["msg"] {
+		case "pengine request":
+			requests++
+			if record["action"] == "" || record["duration"] == nil || record["bytes"] == nil {
+				t.Error("missing fields:", line)
+			}
+			if resp, _ := record["response"].(string); len(resp) > 64+len("... (1000 bytes)") {
+				t.Error("response not truncated:", line)
+			}
+		case "pengine event":
+			events++
+			if record["event"] == "" || record["engine"] == "" {
+				t.Error("missing fields:", line)
+			}
+		}
+	}
+	// create, next
+	if requests != 2 {
+		t.Error("want 2 requests logged, got:", requests)
+	}
+	// create, success, destroy, success
+	if events != 4 {
+		t.Error("want 4 events logged, got:", events, buf.String())
+	}
+}
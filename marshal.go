@@ -0,0 +1,429 @@
+package pengine
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// TermMarshaler is implemented by types that can convert themselves into Prolog terms.
+type TermMarshaler interface {
+	MarshalTerm() (engine.Term, error)
+}
+
+// Marshal converts a Go value into a Prolog term:
+//
+//   - strings to atoms, or to SWI-Prolog strings for struct fields tagged `pengine:",string"`
+//   - bools to the atoms true and false
+//   - integers, floats, and *big.Int to numbers
+//   - slices and arrays to lists
+//   - maps to lists of Key-Value pairs sorted by key, or to SWI-Prolog dicts for struct fields tagged `pengine:",dict"` (see also Dict)
+//   - structs to compounds, with fields as arguments in order, or ordered by tags like `pengine:",arg=1"`
+//   - engine.Term values and Term as-is, and TermMarshaler by calling MarshalTerm
+//   - encoding.TextMarshaler, such as time.Time, to atoms (or strings, with the string option) of its text
+//
+// Structs without exported fields can't be marshaled unless their functor is set by a blank field's tag,
+// in which case they become atoms.
+//
+// A struct's functor is its type name in snake_case, such as http_route for HTTPRoute.
+// It can be set with the tag of a blank field:
+//
+//	type Person struct {
+//		_    struct{} `pengine:"person"`
+//		Name string   `pengine:",string"`
+//		Age  int
+//	}
+//	// person("Alice",42)
+//
+// Fields tagged `pengine:"-"` are skipped, and the fields of untagged embedded structs are flattened
+// into the outer struct, as with Solution.Scan.
+// ichiban/prolog can't represent SWI-Prolog strings, dicts, or integers larger than 64 bits,
+// so they are returned as opaque terms that engine.WriteTerm writes in SWI-Prolog syntax.
+func Marshal(v any) (engine.Term, error) {
+	return marshal(reflect.ValueOf(v), "")
+}
+
+// MarshalText is like Marshal, but returns the term as quoted Prolog text suitable for queries and source code.
+func MarshalText(v any) ([]byte, error) {
+	t, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(stringify(t)), nil
+}
+
+// Dict marshals Map, which must be a map or struct, into an SWI-Prolog dict such as Tag{key:value}.
+// If Tag is empty, the dict's tag is unbound.
+type Dict struct {
+	Tag string
+	Map any
+}
+
+// MarshalTerm implements TermMarshaler.
+func (d Dict) MarshalTerm() (engine.Term, error) {
+	return marshalDict(reflect.ValueOf(d.Map), d.Tag)
+}
+
+// rawText is a term that ichiban/prolog can't represent, such as an SWI-Prolog string.
+// engine.WriteTerm writes it as-is.
+type rawText string
+
+var (
+	termMarshalerType = reflect.TypeOf((*TermMarshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bigIntPtrType     = reflect.TypeOf((*big.Int)(nil))
+)
+
+// marshal converts v using the options of its struct field tag, if any.
+func marshal(v reflect.Value, opt string) (engine.Term, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("pengine: can't marshal nil")
+	}
+	if v.Type().Implements(termMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, fmt.Errorf("pengine: can't marshal nil %s", v.Type())
+		}
+		return v.Interface().(TermMarshaler).MarshalTerm()
+	}
+
+	switch x := v.Interface().(type) {
+	case engine.Atom, engine.Integer, engine.Float, engine.Variable, engine.Compound, rawText:
+		return x, nil
+	case Term:
+		if t := x.Prolog(); t != nil {
+			return t, nil
+		}
+		return nil, fmt.Errorf("pengine: can't marshal empty Term")
+	case big.Int:
+		return marshalBigInt(&x), nil
+	case *big.Int:
+		if x == nil {
+			return nil, fmt.Errorf("pengine: can't marshal nil %s", bigIntPtrType)
+		}
+		return marshalBigInt(x), nil
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, fmt.Errorf("pengine: can't marshal nil %s", v.Type())
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("pengine: can't marshal %s: %w", v.Type(), err)
+		}
+		if opt == "string" {
+			return rawText(quoteString(string(text))), nil
+		}
+		return engine.Atom(text), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if opt == "string" {
+			return rawText(quoteString(v.String())), nil
+		}
+		return engine.Atom(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return engine.Atom("true"), nil
+		}
+		return engine.Atom("false"), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return engine.Integer(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return marshalBigInt(new(big.Int).SetUint64(v.Uint())), nil
+		}
+		return engine.Integer(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("pengine: can't marshal %v", f)
+		}
+		return engine.Float(f), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return engine.Atom("[]"), nil
+		}
+		list := make([]engine.Term, v.Len())
+		for i := range list {
+			t, err := marshal(v.Index(i), "")
+			if err != nil {
+				return nil, err
+			}
+			list[i] = t
+		}
+		return engine.List(list...), nil
+	case reflect.Map:
+		if opt == "dict" {
+			return marshalDict(v, "")
+		}
+		return marshalPairs(v)
+	case reflect.Struct:
+		if opt == "dict" {
+			return marshalDict(v, "")
+		}
+		return marshalStruct(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("pengine: can't marshal nil %s", v.Type())
+		}
+		return marshal(v.Elem(), opt)
+	}
+	return nil, fmt.Errorf("pengine: can't marshal %s", v.Type())
+}
+
+func marshalStruct(v reflect.Value) (engine.Term, error) {
+	functor := functorOf(v.Type())
+	if functor == "" {
+		return nil, fmt.Errorf("pengine: can't marshal %s: no functor", v.Type())
+	}
+	fields, err := argsOf(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 && functorTag(v.Type()) == "" {
+		return nil, fmt.Errorf("pengine: can't marshal %s: no exported fields", v.Type())
+	}
+	args := make([]engine.Term, 0, len(fields))
+	for _, f := range fields {
+		arg, err := marshal(v.FieldByIndex(f.index), f.opt)
+		if err != nil {
+			return nil, fmt.Errorf("pengine: can't marshal field %s of %s: %w", f.name, v.Type(), err)
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return engine.Atom(functor), nil
+	}
+	return engine.Atom(functor).Apply(args...), nil
+}
+
+// marshalPairs converts a map to a list of Key-Value pairs, sorted by key.
+func marshalPairs(v reflect.Value) (engine.Term, error) {
+	keys := sortedKeys(v)
+	pairs := make([]engine.Term, 0, len(keys))
+	for _, key := range keys {
+		k, err := marshal(key, "")
+		if err != nil {
+			return nil, err
+		}
+		val, err := marshal(v.MapIndex(key), "")
+		if err != nil {
+			return nil, fmt.Errorf("pengine: can't marshal map value for %v: %w", key, err)
+		}
+		pairs = append(pairs, engine.Atom("-").Apply(k, val))
+	}
+	return engine.List(pairs...), nil
+}
+
+// marshalDict converts a map or struct to an SWI-Prolog dict.
+func marshalDict(v reflect.Value, tag string) (engine.Term, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, fmt.Errorf("pengine: can't marshal nil dict")
+		}
+		v = v.Elem()
+	}
+
+	type entry struct {
+		key   engine.Term
+		value engine.Term
+	}
+	var entries []entry
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			k, err := marshal(key, "")
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case engine.Atom, engine.Integer:
+			default:
+				return nil, fmt.Errorf("pengine: dict keys must be atoms or integers, got %s", key.Type())
+			}
+			val, err := marshal(v.MapIndex(key), "")
+			if err != nil {
+				return nil, fmt.Errorf("pengine: can't marshal dict value for %v: %w", key, err)
+			}
+			entries = append(entries, entry{key: k, value: val})
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			val, err := marshal(v.FieldByIndex(f.index), f.opt)
+			if err != nil {
+				return nil, fmt.Errorf("pengine: can't marshal field %s of %s: %w", f.name, v.Type(), err)
+			}
+			entries = append(entries, entry{key: engine.Atom(f.name), value: val})
+		}
+	default:
+		return nil, fmt.Errorf("pengine: can't marshal %s as a dict", v.Type())
+	}
+
+	var sb strings.Builder
+	if tag == "" {
+		sb.WriteRune('_')
+	} else {
+		sb.WriteString(escapeAtom(tag))
+	}
+	sb.WriteRune('{')
+	for i, e := range entries {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(stringify(e.key))
+		sb.WriteString(":(")
+		sb.WriteString(stringify(e.value))
+		sb.WriteRune(')')
+	}
+	sb.WriteRune('}')
+	return rawText(sb.String()), nil
+}
+
+func marshalBigInt(n *big.Int) engine.Term {
+	if n.IsInt64() {
+		return engine.Integer(n.Int64())
+	}
+	if n.Sign() < 0 {
+		return rawText("(" + n.String() + ")")
+	}
+	return rawText(n.String())
+}
+
+// sortedKeys returns the keys of a map in a stable order.
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		}
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	})
+	return keys
+}
+
+// argsOf returns the fields of a struct type in argument order:
+// fields with an arg=N tag option at position N, and the rest in declaration order after them.
+// Positions must be distinct integers from 1 to the number of fields.
+func argsOf(typ reflect.Type) ([]field, error) {
+	fields := fieldsOf(typ)
+	pos := make([]int, len(fields))
+	taken := make(map[int]string, len(fields))
+	for i, f := range fields {
+		if f.arg == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f.arg)
+		if err != nil || n < 1 || n > len(fields) {
+			return nil, fmt.Errorf("pengine: invalid arg=%s tag on field %s of %s: want position from 1 to %d", f.arg, f.name, typ, len(fields))
+		}
+		if other, ok := taken[n]; ok {
+			return nil, fmt.Errorf("pengine: fields %s and %s of %s both have arg=%d", other, f.name, typ, n)
+		}
+		taken[n] = f.name
+		pos[i] = n
+	}
+	if len(taken) == 0 {
+		return fields, nil
+	}
+
+	ordered := make([]field, len(fields))
+	var rest []field
+	for i, f := range fields {
+		if pos[i] == 0 {
+			rest = append(rest, f)
+			continue
+		}
+		ordered[pos[i]-1] = f
+	}
+	// fill the positions left over in declaration order
+	for i := range ordered {
+		if ordered[i].index == nil {
+			ordered[i], rest = rest[0], rest[1:]
+		}
+	}
+	return ordered, nil
+}
+
+// functorOf returns the functor for a struct type:
+// the tag of a blank field, or the type's name in snake_case.
+func functorOf(typ reflect.Type) string {
+	if tag := functorTag(typ); tag != "" {
+		return tag
+	}
+	return snakeCase(typ.Name())
+}
+
+// functorTag returns the functor set by the tag of a blank field, or "" if there is none.
+func functorTag(typ reflect.Type) string {
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.Name == "_" {
+			if tag, _, _ := strings.Cut(f.Tag.Get("pengine"), ","); tag != "" {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+// snakeCase converts a Go identifier such as HTTPRoute to snake_case, such as http_route.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				sb.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// quoteString returns str as a double-quoted SWI-Prolog string.
+func quoteString(str string) string {
+	var sb strings.Builder
+	sb.WriteRune('"')
+	for _, r := range str {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if unicode.IsControl(r) {
+				sb.WriteString(`\x` + strconv.FormatInt(int64(r), 16) + `\`)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
@@ -0,0 +1,150 @@
+package pengine
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalTerm() (engine.Term, error) {
+	if p.X < 0 {
+		return nil, errors.New("negative")
+	}
+	return engine.Atom("-").Apply(engine.Integer(p.X), engine.Integer(p.Y)), nil
+}
+
+func TestMarshal(t *testing.T) {
+	type HTTPRoute struct {
+		Method string
+		Path   string `pengine:",string"`
+	}
+	type person struct {
+		_       struct{}        `pengine:"person"`
+		Name    string          `pengine:"name,string"`
+		Age     uint            `pengine:"age"`
+		Tags    []string        `pengine:"tags"`
+		Attrs   map[string]any  `pengine:"attrs,dict"`
+		Scores  map[int]float64 `pengine:"scores"`
+		Home    point           `pengine:"home"`
+		Ignored string          `pengine:"-"`
+		private string
+	}
+	type empty struct {
+		_ struct{} `pengine:"nothing"`
+	}
+	type ordered struct {
+		A int
+		B int `pengine:",arg=1"`
+		C int
+		D int `pengine:",arg=3"`
+	}
+
+	type event struct {
+		At   time.Time
+		Date time.Time `pengine:",string"`
+	}
+	type opaque struct {
+		n int
+	}
+
+	ok := "ok"
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"hello world", "'hello world'"},
+		{engine.Atom("[]"), "[]"},
+		{true, "true"},
+		{-42, "-42"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{big.NewInt(7), "7"},
+		{huge, "123456789012345678901234567890"},
+		{new(big.Int).Neg(huge), "f((-123456789012345678901234567890))"},
+		{[]any{1, "a", []int{}}, "[1,a,[]]"},
+		{[2]bool{true, false}, "[true,false]"},
+		{map[string]int{"b": 2, "a": 1}, "[-(a,1),-(b,2)]"},
+		{HTTPRoute{Method: "GET", Path: `/a"b\c`}, `http_route('GET',"/a\"b\\c")`},
+		{empty{}, "nothing"},
+		{ordered{A: 1, B: 2, C: 3, D: 4}, "ordered(2,1,4,3)"},
+		{day, "'2026-10-17T00:00:00Z'"},
+		{event{At: day, Date: day}, `event('2026-10-17T00:00:00Z',"2026-10-17T00:00:00Z")`},
+		{point{1, 2}, "-(1,2)"},
+		{&point{3, 4}, "-(3,4)"},
+		{Dict{Tag: "point", Map: map[string]int{"y": 2, "x": 1}}, "point{x:(1),y:(2)}"},
+		{Dict{Map: struct{ A, B string }{"x", "y"}}, "_{'A':(x),'B':(y)}"},
+		{Term{Atom: &ok}, "ok"},
+		{
+			person{
+				Name:   "Alice",
+				Age:    42,
+				Tags:   []string{"admin"},
+				Attrs:  map[string]any{"lang": "en", "dark": true},
+				Scores: map[int]float64{2: 0.5, 1: 1},
+				Home:   point{1, 2},
+			},
+			`person("Alice",42,[admin],_{dark:(true),lang:(en)},[-(1,1.0),-(2,0.5)],-(1,2))`,
+		},
+	}
+	for _, test := range tests {
+		in := test.in
+		if n, ok := in.(*big.Int); ok && n.Sign() < 0 {
+			// wrap to check that negative big integers stay well-formed as arguments
+			in = struct {
+				_ struct{} `pengine:"f"`
+				N *big.Int
+			}{N: n}
+		}
+		got, err := MarshalText(in)
+		if err != nil {
+			t.Errorf("%#v: %v", test.in, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%#v:\nwant: %s\ngot:  %s", test.in, test.want, got)
+		}
+	}
+
+	bad := []any{
+		nil,
+		(*point)(nil),
+		point{-1, 0},
+		math.NaN(),
+		make(chan int),
+		struct{ A int }{1},
+		opaque{n: 1},
+		(*time.Time)(nil),
+		Dict{Map: map[float64]int{1.5: 1}},
+		Dict{Map: 1},
+	}
+	for _, in := range bad {
+		if got, err := MarshalText(in); err == nil {
+			t.Errorf("%#v: want error, got: %s", in, got)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Person":     "person",
+		"HTTPRoute":  "http_route",
+		"userID":     "user_id",
+		"Point3D":    "point3_d",
+		"already_ok": "already_ok",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
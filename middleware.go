@@ -0,0 +1,51 @@
+package pengine
+
+import (
+	"net/http"
+	"time"
+)
+
+// Middleware hooks into every HTTP request a Client sends to the pengines server.
+// Either function may be nil.
+type Middleware struct {
+	// BeforeRequest is called before the request is sent, after authentication.
+	// It may modify the request, for example to add tracing headers.
+	// If it returns an error, the request is not sent and the error is returned to the caller.
+	BeforeRequest func(req *http.Request, info RequestInfo) error
+	// AfterResponse is called after the request completes.
+	// Either resp or err will be set. The response body must not be consumed.
+	AfterResponse func(req *http.Request, resp *http.Response, info RequestInfo, err error)
+}
+
+// RequestInfo describes a request sent to a pengines server.
+type RequestInfo struct {
+	// EngineID is the ID of the pengine this request is for, empty when creating a pengine.
+	EngineID string
+	// Action is the pengines API action: create, send, ping, pull_response, or abort.
+	Action string
+	// Format is the response format requested: json, json-s, or prolog.
+	Format string
+	// Start is the time the request began, useful for measuring latency.
+	Start time.Time
+}
+
+// runMiddleware sends req, calling the client's middleware around it.
+// BeforeRequest hooks are called in order and AfterResponse hooks in reverse order.
+func (c Client) runMiddleware(req *http.Request, info RequestInfo) (*http.Response, error) {
+	info.Start = time.Now()
+	for _, mw := range c.Middleware {
+		if mw.BeforeRequest == nil {
+			continue
+		}
+		if err := mw.BeforeRequest(req, info); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.client().Do(req)
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		if after := c.Middleware[i].AfterResponse; after != nil {
+			after(req, resp, info, err)
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,92 @@
+package pengine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var log []RequestInfo
+	c := srv.client()
+	c.Middleware = []Middleware{
+		{
+			BeforeRequest: func(req *http.Request, info RequestInfo) error {
+				req.Header.Set("X-Trace", "abc")
+				return nil
+			},
+		},
+		{
+			AfterResponse: func(req *http.Request, resp *http.Response, info RequestInfo, err error) {
+				if req.Header.Get("X-Trace") != "abc" {
+					t.Error("missing trace header")
+				}
+				if err != nil || resp.StatusCode != http.StatusOK {
+					t.Error("unexpected response:", resp, err)
+				}
+				if info.Start.IsZero() {
+					t.Error("missing start time")
+				}
+				mu.Lock()
+				log = append(log, info)
+				mu.Unlock()
+			},
+		},
+	}
+
+	eng, err := c.Create(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	as, err := eng.Ask(ctx, "between(1,2,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for as.Next(ctx) {
+	}
+	if err := as.Err(); err != nil {
+		t.Error(err)
+	}
+
+	want := []RequestInfo{
+		{Action: "create", Format: "json"},
+		{EngineID: eng.ID(), Action: "ping", Format: "json"},
+		{EngineID: eng.ID(), Action: "send", Format: "json"},
+		{EngineID: eng.ID(), Action: "send", Format: "json"},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(log) != len(want) {
+		t.Fatalf("want %d requests, got: %v", len(want), log)
+	}
+	for i, info := range log {
+		info.Start = want[i].Start
+		if info != want[i] {
+			t.Errorf("request %d: want %+v, got %+v", i, want[i], info)
+		}
+	}
+
+	t.Run("before error", func(t *testing.T) {
+		errNope := errors.New("nope")
+		c := srv.client()
+		c.Middleware = []Middleware{{
+			BeforeRequest: func(*http.Request, RequestInfo) error { return errNope },
+		}}
+		before := srv.requestCount()
+		if _, err := c.Create(ctx, false); !errors.Is(err, errNope) {
+			t.Error("want errNope, got:", err)
+		}
+		if srv.requestCount() != before {
+			t.Error("request should not be sent")
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package pengine
+
+import (
+	"context"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// Output is a message sent by a remote goal calling pengine_output/1.
+type Output struct {
+	// ID is the ID of the pengine that sent this output.
+	ID string
+	// Data is the output term, set when using the JSON format.
+	Data Term
+	// Prolog is the output term, set when using the Prolog format.
+	Prolog engine.Term
+}
+
+type outputKey struct{}
+
+// WithOutput returns a context that will call fn for each pengine_output/1 event
+// of queries started with it. This is called in addition to Client.OnOutput.
+func WithOutput(ctx context.Context, fn func(Output)) context.Context {
+	return context.WithValue(ctx, outputKey{}, fn)
+}
+
+func outputFrom(ctx context.Context) func(Output) {
+	fn, _ := ctx.Value(outputKey{}).(func(Output))
+	return fn
+}
+
+func (as *iterator[T]) output(out Output) {
+	if as.eng.client.OnOutput != nil {
+		as.eng.client.OnOutput(out)
+	}
+	if as.onOutput != nil {
+		as.onOutput(out)
+	}
+}
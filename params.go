@@ -0,0 +1,236 @@
+package pengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// NamedArg is a named query argument, bound to the ?name placeholder. See Named.
+type NamedArg struct {
+	Name  string
+	Value any
+}
+
+// Named returns a query argument bound to the placeholder ?name.
+//
+//	client.Ask(ctx, "member(X, ?list), X > ?min", pengine.Named("list", []int{1, 2, 3}), pengine.Named("min", 1))
+func Named(name string, value any) NamedArg {
+	return NamedArg{Name: name, Value: value}
+}
+
+// bind substitutes args for the placeholders in query.
+// ? is a positional placeholder, bound to the unnamed arguments in order, and ?name is bound to Named("name", value).
+// Placeholders inside quoted atoms, strings, and comments are ignored,
+// as are question marks that are part of a larger symbol token, such as =? in X=?.
+//
+// Arguments are written as quoted Prolog terms, so they can't change the structure of the query.
+// The result is parsed with the client's interpreter to check that it is a single goal.
+// If there are no arguments, query is returned as-is.
+func (c Client) bind(query string, args []any) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+
+	var positional []any
+	named := make(map[string]any)
+	for _, arg := range args {
+		if arg, ok := arg.(NamedArg); ok {
+			if _, dup := named[arg.Name]; dup {
+				return "", fmt.Errorf("pengine: duplicate argument %s", arg.Name)
+			}
+			named[arg.Name] = arg.Value
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	var sb strings.Builder
+	var next int
+	var joined string // a symbol token like =? that could be a mistaken placeholder
+	used := make(map[string]bool, len(named))
+	for i := 0; i < len(query); {
+		ch := query[i]
+		var j int
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			j = skipQuoted(query, i)
+		case ch == '%':
+			j = strings.IndexByte(query[i:], '\n')
+			if j == -1 {
+				j = len(query)
+			} else {
+				j += i
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			j = strings.Index(query[i+2:], "*/")
+			if j == -1 {
+				j = len(query)
+			} else {
+				j += i + 4
+			}
+		case strings.HasPrefix(query[i:], "0'"):
+			// character code literal such as 0'?
+			j = i + 3
+			if strings.HasPrefix(query[i+2:], "\\") || strings.HasPrefix(query[i+2:], "''") {
+				j++
+			}
+			j = min(j, len(query))
+		case isAlnum(ch):
+			for j = i; j < len(query) && isAlnum(query[j]); j++ {
+			}
+		case isSymbolChar(ch):
+			for j = i; j < len(query) && isSymbolChar(query[j]); j++ {
+			}
+			if tok := query[i:j]; tok != "?" {
+				if joined == "" && strings.HasSuffix(tok, "?") {
+					joined = tok
+				}
+				break
+			}
+			// placeholder
+			var value any
+			k := j
+			for k < len(query) && isAlnum(query[k]) {
+				k++
+			}
+			if name := query[j:k]; name != "" {
+				v, ok := named[name]
+				if !ok {
+					return "", fmt.Errorf("pengine: missing argument for placeholder ?%s", name)
+				}
+				used[name] = true
+				value = v
+			} else {
+				if next >= len(positional) {
+					return "", fmt.Errorf("pengine: not enough arguments for placeholders: got %d", len(positional))
+				}
+				value = positional[next]
+				next++
+			}
+			text, err := paramText(value)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(" (")
+			sb.WriteString(text)
+			sb.WriteString(") ")
+			i = k
+			continue
+		default:
+			j = i + 1
+		}
+		sb.WriteString(query[i:j])
+		i = j
+	}
+
+	var err error
+	if next != len(positional) {
+		err = fmt.Errorf("pengine: too many arguments for placeholders: got %d, want %d", len(positional), next)
+	}
+	for name := range named {
+		if err == nil && !used[name] {
+			err = fmt.Errorf("pengine: unused argument %s: no placeholder ?%s", name, name)
+		}
+	}
+	if err != nil {
+		if joined != "" {
+			err = fmt.Errorf("%w (? must stand alone as a token, but found %s; try adding a space before ?)", err, joined)
+		}
+		return "", err
+	}
+
+	bound := sb.String()
+	if err := c.checkGoal(bound); err != nil {
+		return "", err
+	}
+	return bound, nil
+}
+
+// checkGoal parses query to make sure it is a single callable term.
+func (c Client) checkGoal(query string) error {
+	parser := c.interpreter().Parser(strings.NewReader(query+"\n."), nil)
+	t, err := parser.Term()
+	if err != nil {
+		return fmt.Errorf("pengine: invalid query: %w", err)
+	}
+	if parser.More() {
+		return fmt.Errorf("pengine: invalid query: more than one term")
+	}
+	switch t.(type) {
+	case engine.Atom, engine.Compound:
+		return nil
+	}
+	return fmt.Errorf("pengine: invalid query: %s is not callable", stringify(t))
+}
+
+// paramText returns the Prolog text for a query argument, converted with Marshal.
+// Variables are not allowed.
+func paramText(v any) (string, error) {
+	t, err := Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if err := checkParam(t); err != nil {
+		return "", err
+	}
+	return stringify(t), nil
+}
+
+// checkParam makes sure a query argument has no variables
+// and that it can be read by the local parser, which checks the bound query.
+// SWI-Prolog strings are allowed, but dicts and integers larger than 64 bits are not.
+func checkParam(t engine.Term) error {
+	for {
+		switch x := t.(type) {
+		case engine.Variable:
+			return fmt.Errorf("pengine: can't use variable %s as a query argument", x)
+		case rawText:
+			switch {
+			case strings.HasPrefix(string(x), `"`):
+				return nil
+			case strings.TrimLeft(string(x), "(-0123456789)") == "":
+				return fmt.Errorf("pengine: query argument %s out of range", strings.Trim(string(x), "()"))
+			}
+			return fmt.Errorf("pengine: %s can't be used as a query argument", x)
+		case engine.Compound:
+			// check the last argument iteratively, so long lists don't recurse deeply
+			n := x.Arity()
+			for i := 0; i < n-1; i++ {
+				if err := checkParam(x.Arg(i)); err != nil {
+					return err
+				}
+			}
+			t = x.Arg(n - 1)
+			continue
+		}
+		return nil
+	}
+}
+
+// skipQuoted returns the index after the quoted text starting at query[i].
+func skipQuoted(query string, i int) int {
+	quote := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			j++
+		case quote:
+			if j+1 < len(query) && query[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+func isAlnum(ch byte) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+func isSymbolChar(ch byte) bool {
+	return strings.IndexByte(`+-*/\^<>=~:.?@#&$`, ch) != -1
+}
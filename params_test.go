@@ -0,0 +1,121 @@
+package pengine
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestBind(t *testing.T) {
+	type text struct {
+		_ struct{} `pengine:"text"`
+		S string   `pengine:",string"`
+	}
+	var c Client
+	tests := []struct {
+		query string
+		args  []any
+		want  string
+	}{
+		{
+			query: "member(X, ?), X > ?",
+			args:  []any{[]int{1, 2, 3}, 1},
+			want:  "member(X,  ([1,2,3]) ), X >  (1) ",
+		},
+		{
+			query: "X = ?, Y = ?name",
+			args:  []any{Named("name", "O'Brien"), "a), shell(rm"},
+			want:  "X =  ('a), shell(rm') , Y =  ('O\\'Brien') ",
+		},
+		{
+			query: `X = '?', Y = "?", Z = 0'?, W @>= ? % ?`,
+			args:  []any{-1.5},
+			want:  `X = '?', Y = "?", Z = 0'?, W @>=  (-1.5)  % ?`,
+		},
+		{
+			query: "X = ?",
+			args:  []any{engine.Atom("foo").Apply(engine.Integer(1))},
+			want:  "X =  (foo(1)) ",
+		},
+		{
+			query: "X = ?",
+			args:  []any{map[string]int{"b": 2, "a": 1}},
+			want:  "X =  ([-(a,1),-(b,2)]) ",
+		},
+		{
+			query: "X = ?",
+			args:  []any{text{S: `a"b`}},
+			want:  `X =  (text("a\"b")) `,
+		},
+	}
+	for _, test := range tests {
+		got, err := c.bind(test.query, test.args)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s:\nwant: %s\ngot:  %s", test.query, test.want, got)
+		}
+	}
+
+	bad := []struct {
+		query string
+		args  []any
+	}{
+		{"X = ?", []any{1, 2}},
+		{"X = ?, Y = ?", []any{1}},
+		{"X = ?a", []any{Named("b", 1)}},
+		{"X = ?", []any{engine.Variable("Y")}},
+		{"X = ?", []any{[]any{1, engine.Atom("f").Apply(engine.Variable("Y"))}}},
+		{"X = ?", []any{Dict{Map: map[string]int{"a": 1}}}},
+		{"X = ?", []any{uint64(1 << 63)}},
+		{"X = ?", []any{new(big.Int).Lsh(big.NewInt(1), 70)}},
+		{"X = ?", []any{make(chan int)}},
+		{"X = ?). (Y", []any{1}},
+		{"?", []any{1}},
+		{"X = ?a, Y = ?a", []any{Named("a", 1), Named("a", 2)}},
+	}
+	for _, test := range bad {
+		if got, err := c.bind(test.query, test.args); err == nil {
+			t.Errorf("%s: want error, got: %s", test.query, got)
+		}
+	}
+
+	_, err := c.bind("X=?", []any{1})
+	if want := "? must stand alone as a token, but found =?"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, got: %v", want, err)
+	}
+	_, err = c.bind("X=?a", []any{Named("a", 1)})
+	if want := "? must stand alone as a token, but found =?"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, got: %v", want, err)
+	}
+
+	_, err = c.bind("X = ?", []any{uint64(1 << 63)})
+	if want := "pengine: query argument 9223372036854775808 out of range"; err == nil || err.Error() != want {
+		t.Errorf("want error %q, got: %v", want, err)
+	}
+}
+
+func TestAskArgs(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	as, err := srv.client().Ask(ctx, "between(1, ?, X)", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for as.Next(ctx) {
+		n++
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Error("want 3 answers, got:", n)
+	}
+}
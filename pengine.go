@@ -3,6 +3,8 @@ package pengine
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 
 	"github.com/ichiban/prolog/engine"
 )
@@ -12,6 +14,14 @@ var (
 	ErrDead = fmt.Errorf("pengine: died")
 	// ErrFailed is an error returned when a query failed (returned no results).
 	ErrFailed = fmt.Errorf("pengine: query failed")
+	// ErrPrompt is an error returned when a query is waiting for input from pengine_input/2 but no prompt handler is set.
+	// Use Engine.Respond to reply and resume the query.
+	ErrPrompt = fmt.Errorf("pengine: unhandled prompt")
+	// ErrAborted is an error returned when a query was interrupted by Engine.Abort.
+	ErrAborted = fmt.Errorf("pengine: query aborted")
+	// ErrBusy is an error returned when asking a pengine that is already running a query.
+	// Finish iterating through the previous query's answers or Close it first.
+	ErrBusy = fmt.Errorf("pengine: a query is already running")
 )
 
 // Ask creates a new pengine with the given initial query, executing it and returning an answers iterator.
@@ -21,12 +31,49 @@ var (
 //
 // This uses the JSON format, so T can be anything that can unmarshal from the pengine result data.
 // This package provides a Solutions type that can handle most results in a general manner.
-func Ask[T any](ctx context.Context, c Client, query string) (Answers[T], error) {
-	eng, answer, err := c.create(ctx, query, true)
+//
+// Values can be safely passed to the query with placeholders: ? is bound to the next unnamed argument,
+// and ?name is bound to the argument given by Named("name", value).
+// Arguments are converted with Marshal and written as quoted Prolog terms (strings become atoms), and the resulting query
+// is parsed locally to make sure it is a single goal.
+// A placeholder must stand alone as a token: X = ? works, but X=? is read as X followed by the operator =?.
+// For example:
+//
+//	Ask[Solution](ctx, client, "member(X, ?), X > ?", []int{1, 2, 3}, 1)
+//	// answers: {X: 2}, {X: 3}
+func Ask[T any](ctx context.Context, c Client, query string, args ...any) (Answers[T], error) {
+	query, err := c.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	eng, answer, err := c.create(ctx, query, "", true)
 	if err != nil {
 		return nil, err
 	}
-	return newIterator[T](eng, answer)
+	return startIterator[T](ctx, eng, answer)
+}
+
+// AskTemplate is like Ask, but each answer is the given template instantiated by the query
+// instead of a mapping of variable names to values. For example:
+//
+//	AskTemplate[[]int](ctx, client, "between(1,3,X), Y is X*X", "[X,Y]")
+//	// answers: [1,1], [2,4], [3,9]
+//
+// See the Ask function for placeholders.
+//
+// Compound templates such as row(X,Y) are encoded by pengines as {"functor": "row", "args": [...]},
+// which can be unmarshaled into Term or Compound.
+// With FormatJSONS, T must be Term.
+func AskTemplate[T any](ctx context.Context, c Client, query, template string, args ...any) (Answers[T], error) {
+	query, err := c.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	eng, answer, err := c.create(ctx, query, template, true)
+	if err != nil {
+		return nil, err
+	}
+	return startIterator[T](ctx, eng, answer)
 }
 
 // AskProlog creates a new pengine with the given initial query, executing it and returning an answers iterator.
@@ -35,46 +82,114 @@ func Ask[T any](ctx context.Context, c Client, query string) (Answers[T], error)
 //	between(1,3,X)
 //
 // This uses the Prolog format and answers are ichiban/prolog terms.
+// See the Ask function for placeholders.
 // Because ichiban/prolog is used to interpret results, using SWI's nonstandard syntax extensions like dictionaries may break it.
-func AskProlog(ctx context.Context, c Client, query string) (Answers[engine.Term], error) {
-	return c.createProlog(ctx, query)
+func AskProlog(ctx context.Context, c Client, query string, args ...any) (Answers[engine.Term], error) {
+	query, err := c.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.createProlog(ctx, query, "", true)
+}
+
+// AskPrologBindings is like AskProlog, but each answer is a map of the query's variable names to their values,
+// like the JSON format's Solution. For example:
+//
+//	AskPrologBindings(ctx, client, "between(1,3,X), Y is X*X")
+//	// answers: {X: 1, Y: 1}, {X: 2, Y: 4}, {X: 3, Y: 9}
+//
+// The query is parsed locally with Client.Interpreter to find its variables, which are sent as a Name=Var list template.
+// Variables starting with an underscore are omitted.
+func AskPrologBindings(ctx context.Context, c Client, query string, args ...any) (Answers[map[string]engine.Term], error) {
+	query, err := c.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	template, err := c.bindingsTemplate(query)
+	if err != nil {
+		return nil, err
+	}
+	as, err := c.createProlog(ctx, query, template, true)
+	if err != nil {
+		return nil, err
+	}
+	return convert(as, bindingsMap), nil
 }
 
 // Engine is a pengine.
+// It is safe for concurrent use: requests to the pengine are serialized,
+// and only one query may run at a time.
 type Engine struct {
+	client  Client
+	destroy bool         // automatically destroy if true (default)
+	log     *slog.Logger // nil if logging is disabled
+	prolog  bool         // created with the Prolog format
+
+	mu        sync.Mutex // protects the fields below
 	id        string
-	client    Client
-	openLimit int  // TODO: use this
-	destroy   bool // automatically destroy if true (default)
+	openLimit int // maximum number of pengines the server allows a client to open
 	dead      bool
-	debug     bool
+	query     responder    // current query
+	stream    *eventStream // current Events poller
+	pending   *queryStats  // stats of a create request with a query, before the query is claimed
+
+	reqMu sync.Mutex // serializes requests
 }
 
 // ID return this pengine's ID.
 func (e *Engine) ID() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	return e.id
 }
 
 // Ask queries the pengine, returning an answers iterator.
-func (e *Engine) Ask(ctx context.Context, query string) (Answers[Solution], error) {
-	if e.dead {
-		return nil, ErrDead
+// See the Ask function for placeholders.
+// Returns ErrBusy if another query is running.
+func (e *Engine) Ask(ctx context.Context, query string, args ...any) (Answers[Solution], error) {
+	return engineAsk[Solution](ctx, e, query, "", args)
+}
+
+// AskTemplate queries the pengine, returning an iterator of the given template instantiated by each answer.
+// See the AskTemplate function for details.
+// Returns ErrBusy if another query is running.
+func (e *Engine) AskTemplate(ctx context.Context, query, template string, args ...any) (Answers[Term], error) {
+	return engineAsk[Term](ctx, e, query, template, args)
+}
+
+func engineAsk[T any](ctx context.Context, e *Engine, query, template string, args []any) (Answers[T], error) {
+	query, err := e.client.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	as := newIterator[T](ctx, e)
+	if err := e.claim(as); err != nil {
+		return nil, err
 	}
-	opts := e.client.options("prolog")
+	answer, err := e.ask(ctx, &as.stats, query, template)
+	if err != nil {
+		e.release(as)
+		return nil, err
+	}
+	return as, as.start(answer)
+}
+
+func (e *Engine) ask(ctx context.Context, stats *queryStats, query, template string) (answer, error) {
+	opts := e.client.askOptions()
 	opts.Destroy = e.destroy
+	opts.Template = template
 	query = "ask((" + query + "), " + opts.String() + ")"
-	answer, err := e.send(ctx, query)
+	answer, err := e.send(ctx, stats, query)
 	if err != nil {
-		return nil, fmt.Errorf("pengine ask error: %w", err)
+		return answer, fmt.Errorf("pengine ask error: %w", err)
 	}
-	return newIterator[Solution](e, answer)
+	return answer, nil
 }
 
 func (e *Engine) handle(a answer) error {
 	switch a.Event {
 	case "create":
-		e.id = a.ID
-		e.openLimit = a.OpenLimit
+		e.created(a.ID, a.OpenLimit)
 	case "destroy", "died":
 		e.die()
 	}
@@ -86,46 +201,131 @@ func (e *Engine) handle(a answer) error {
 
 // Pings this pengine, returning ErrDead if it is dead.
 func (e *Engine) Ping(ctx context.Context) error {
-	if e.dead {
+	if e.isDead() {
 		return ErrDead
 	}
 
-	a, err := e.get(ctx, "ping", "json")
+	var a answer
+	err := e.client.retry(ctx, func() (err error) {
+		a, err = e.get(ctx, nil, "ping", "json")
+		return
+	})
 	if err != nil {
 		return err
 	}
 	if err := e.handle(a); err != nil {
 		return err
 	}
-	if e.dead {
+	if e.isDead() {
 		return ErrDead
 	}
 	return nil
 }
 
+// Abort interrupts the query currently running on this pengine.
+// Unlike Answers.Close, this does not wait for the query to finish computing its current answer,
+// so it can be used to stop a query that is stuck in Answers.Next from another goroutine.
+// The interrupted query's Err method will return ErrAborted.
+func (e *Engine) Abort(ctx context.Context) error {
+	if e.isDead() {
+		return ErrDead
+	}
+	return e.client.retry(ctx, func() error {
+		return e.abort(ctx)
+	})
+}
+
 // Close destroys this engine. It is usually not necessary to do this as pengines will destroy themselves automatically unless configured differently.
 func (e *Engine) Close() error {
-	if e.dead {
+	if e.isDead() {
 		return nil
 	}
-	a, err := e.send(context.Background(), "destroy")
+	ctx := context.Background()
+	if e.prolog {
+		a, err := e.sendProlog(ctx, nil, "destroy")
+		if err != nil {
+			return err
+		}
+		return newProlog(ctx, e).handle(ctx, a)
+	}
+	a, err := e.send(ctx, nil, "destroy")
 	if err != nil {
 		return err
 	}
 	return e.handle(a)
 }
 
+func isAborted(ball engine.Term) bool {
+	switch ball := ball.(type) {
+	case engine.Atom:
+		return ball == "$aborted" || ball == "abort_query" || ball == "aborted"
+	case engine.Compound:
+		switch {
+		case ball.Functor() == "unwind" && ball.Arity() == 1:
+			return ball.Arg(0) == engine.Atom("abort")
+		case ball.Functor() == "error" && ball.Arity() == 2:
+			return isAborted(ball.Arg(0))
+		}
+	}
+	return false
+}
+
 func (e *Engine) die() {
+	e.mu.Lock()
 	e.dead = true
+	e.mu.Unlock()
+}
+
+func (e *Engine) isDead() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dead
+}
+
+func (e *Engine) created(id string, openLimit int) {
+	e.mu.Lock()
+	e.id = id
+	e.openLimit = openLimit
+	e.mu.Unlock()
+}
+
+// limit returns the maximum number of pengines the server allows a client to open, or 0 if unknown.
+func (e *Engine) limit() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.openLimit
 }
 
-// Error is an error from the pengines API.
-type Error struct {
-	Code string
-	Data string
+// busy returns true if this pengine is running a query.
+func (e *Engine) busy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.query != nil
 }
 
-// Error implements the error interface.
-func (err Error) Error() string {
-	return fmt.Sprintf("pengine: %s: %s", err.Code, err.Data)
+// claim sets q as the running query, failing if another query is running.
+func (e *Engine) claim(q responder) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dead {
+		return ErrDead
+	}
+	if e.query != nil {
+		return ErrBusy
+	}
+	e.query = q
+	if st, ok := q.(statser); ok && e.pending != nil {
+		st.queryStats().merge(e.pending)
+	}
+	e.pending = nil
+	return nil
+}
+
+// release unsets q as the running query.
+func (e *Engine) release(q responder) {
+	e.mu.Lock()
+	if e.query == q {
+		e.query = nil
+	}
+	e.mu.Unlock()
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ichiban/prolog/engine"
 )
@@ -106,4 +107,166 @@ func TestPengines(t *testing.T) {
 			t.Error("want:", ErrDead, "got:", err)
 		}
 	})
+	t.Run("output", func(t *testing.T) {
+		var got []Output
+		ctx := WithOutput(ctx, func(out Output) {
+			got = append(got, out)
+		})
+		as, err := client.Ask(ctx, "pengine_output(hello), member(X, [1, 2]), pengine_output(X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for as.Next(ctx) {
+			n++
+		}
+		if err := as.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Error("answer len mismatch. want: 2 got:", n)
+		}
+		want := []engine.Term{engine.Atom("hello"), engine.Integer(1), engine.Integer(2)}
+		if len(got) != len(want) {
+			t.Fatal("output len mismatch. want:", len(want), "got:", len(got))
+		}
+		for i, out := range got {
+			if !reflect.DeepEqual(want[i], out.Data.Prolog()) {
+				t.Error("unexpected output. want:", want[i], "got:", out.Data.Prolog())
+			}
+		}
+	})
+	t.Run("prompt", func(t *testing.T) {
+		ctx := WithPrompt(ctx, func(ctx context.Context, prompt Prompt) (engine.Term, error) {
+			if got := prompt.Data.Prolog(); got != engine.Atom("name") {
+				t.Error("unexpected prompt. want: name got:", got)
+			}
+			return engine.Atom("alice"), nil
+		})
+		as, err := client.Ask(ctx, "pengine_input(name, X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !as.Next(ctx) {
+			t.Fatal("no answer:", as.Err())
+		}
+		if got := as.Current()["X"].Prolog(); got != engine.Atom("alice") {
+			t.Error("unexpected answer. want: alice got:", got)
+		}
+	})
+
+	t.Run("respond", func(t *testing.T) {
+		as, err := client.Ask(ctx, "pengine_input(name, X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if as.Next(ctx) {
+			t.Fatal("unexpected answer:", as.Current())
+		}
+		if err := as.Err(); err != ErrPrompt {
+			t.Fatal("want:", ErrPrompt, "got:", err)
+		}
+		if err := as.Engine().Respond(ctx, engine.Atom("bob")); err != nil {
+			t.Fatal(err)
+		}
+		if !as.Next(ctx) {
+			t.Fatal("no answer:", as.Err())
+		}
+		if got := as.Current()["X"].Prolog(); got != engine.Atom("bob") {
+			t.Error("unexpected answer. want: bob got:", got)
+		}
+	})
+	t.Run("abort", func(t *testing.T) {
+		eng, err := client.Create(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			if err := eng.Abort(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+		as, err := eng.Ask(ctx, "repeat, fail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if as.Next(ctx) {
+			t.Error("unexpected answer:", as.Current())
+		}
+		if err := as.Err(); err != ErrAborted {
+			t.Error("want:", ErrAborted, "got:", err)
+		}
+	})
+	t.Run("events", func(t *testing.T) {
+		eng, err := client.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		// the first output event is returned by ask, the rest are pulled
+		if _, err := eng.Ask(ctx, "pengine_output(a), pengine_output(b), X = 1"); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for evt := range eng.Events(ctx) {
+			if evt.Err != nil {
+				t.Fatal(evt.Err)
+			}
+			got = append(got, evt.Type)
+		}
+		want := []string{"output", "success"}
+		if !reflect.DeepEqual(want, got) {
+			t.Error("unexpected events. want:", want, "got:", got)
+		}
+	})
+	t.Run("template", func(t *testing.T) {
+		as, err := AskTemplate[[]int](ctx, client, "between(1,3,X), Y is X*X", "[X,Y]")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got [][]int
+		for as.Next(ctx) {
+			got = append(got, as.Current())
+		}
+		if err := as.Err(); err != nil {
+			t.Fatal(err)
+		}
+		want := [][]int{{1, 1}, {2, 4}, {3, 9}}
+		if !reflect.DeepEqual(want, got) {
+			t.Error("bad results. want:", want, "got:", got)
+		}
+	})
+	t.Run("json-s", func(t *testing.T) {
+		client := client
+		client.JSONFormat = FormatJSONS
+		as, err := client.Ask(ctx, "X = '_', Y = \"text\", Z = f(_)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !as.Next(ctx) {
+			t.Fatal("no answer:", as.Err())
+		}
+		cur := as.Current()
+		if cur["X"].Atom == nil || *cur["X"].Atom != "_" {
+			t.Error("want atom '_', got:", cur["X"])
+		}
+		if cur["Y"].String == nil || *cur["Y"].String != "text" {
+			t.Error("want string \"text\", got:", cur["Y"])
+		}
+		if cur["Z"].Compound == nil || cur["Z"].Compound.Args[0].Variable == nil {
+			t.Error("want f(_), got:", cur["Z"])
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		as, err := client.Ask(ctx, "this_predicate_does_not_exist(X)")
+		if err == nil {
+			for as.Next(ctx) {
+			}
+			err = as.Err()
+		}
+		if !IsExistenceError(err) {
+			t.Error("want existence error, got:", err)
+		}
+	})
 }
@@ -0,0 +1,230 @@
+package pengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// ErrPoolClosed is an error returned when using an EnginePool that has been closed.
+var ErrPoolClosed = fmt.Errorf("pengine: pool closed")
+
+// EnginePool is a pool of pre-created pengines that have already loaded the client's source.
+// This avoids creating a new pengine and uploading SourceText for every query.
+// It is safe for concurrent use.
+//
+// Engines are created with destroy set to false, so they stay alive between queries.
+// Each engine is health-checked with Engine.Ping before it is handed out, and dead engines are replaced.
+type EnginePool struct {
+	client Client
+	size   int
+	idle   chan *Engine
+	open   chan struct{} // one token per open engine
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewEnginePool creates a pool of size pengines.
+// If the server limits the number of pengines a client may open (slave_limit), the size is reduced to fit.
+func NewEnginePool(ctx context.Context, c Client, size int) (*EnginePool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pengine: invalid pool size: %d", size)
+	}
+
+	first, err := c.Create(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if limit := first.limit(); limit > 0 && size > limit {
+		size = limit
+	}
+
+	pool := &EnginePool{
+		client: c,
+		size:   size,
+		idle:   make(chan *Engine, size),
+		open:   make(chan struct{}, size),
+	}
+	pool.open <- struct{}{}
+	pool.idle <- first
+	for i := 1; i < size; i++ {
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.open <- struct{}{}
+		pool.idle <- eng
+	}
+	return pool, nil
+}
+
+// Size returns the maximum number of pengines in this pool.
+func (pool *EnginePool) Size() int {
+	return pool.size
+}
+
+// Get takes a pengine from the pool, waiting for one to be available if necessary.
+// Return it with Put when finished.
+func (pool *EnginePool) Get(ctx context.Context) (*Engine, error) {
+	for {
+		if pool.isClosed() {
+			return nil, ErrPoolClosed
+		}
+
+		var eng *Engine
+		select {
+		case eng = <-pool.idle:
+		default:
+			select {
+			case eng = <-pool.idle:
+			case pool.open <- struct{}{}:
+				// replace a dead engine
+				created, err := pool.client.Create(ctx, false)
+				if err != nil {
+					<-pool.open
+					return nil, err
+				}
+				if pool.isClosed() {
+					// closed while creating
+					pool.discard(created)
+					return nil, ErrPoolClosed
+				}
+				return created, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if err := eng.Ping(ctx); err != nil {
+			if ctx.Err() != nil {
+				pool.Put(eng)
+				return nil, ctx.Err()
+			}
+			pool.discard(eng)
+			continue
+		}
+		return eng, nil
+	}
+}
+
+// Put returns a pengine taken with Get to the pool.
+// Dead pengines, pengines still running a query, and pengines that don't fit in the pool are discarded.
+func (pool *EnginePool) Put(eng *Engine) {
+	pool.mu.Lock()
+	if !pool.closed && !eng.isDead() && !eng.busy() {
+		select {
+		case pool.idle <- eng:
+			pool.mu.Unlock()
+			return
+		default:
+			// put twice, or from another pool
+		}
+	}
+	pool.mu.Unlock()
+	pool.discard(eng)
+}
+
+// Ask takes a pengine from the pool and queries it.
+// The pengine is returned to the pool when the answers have been iterated through or closed.
+func (pool *EnginePool) Ask(ctx context.Context, query string, args ...any) (Answers[Solution], error) {
+	eng, err := pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	as, err := eng.Ask(ctx, query, args...)
+	if err != nil {
+		pool.Put(eng)
+		return nil, err
+	}
+	return &pooled[Solution]{Answers: as, pool: pool}, nil
+}
+
+// AskProlog takes a pengine from the pool and queries it with Prolog format results.
+// The pengine is returned to the pool when the answers have been iterated through or closed.
+func (pool *EnginePool) AskProlog(ctx context.Context, query string, args ...any) (Answers[engine.Term], error) {
+	eng, err := pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	as, err := eng.AskProlog(ctx, query, args...)
+	if err != nil {
+		pool.Put(eng)
+		return nil, err
+	}
+	return &pooled[engine.Term]{Answers: as, pool: pool}, nil
+}
+
+// Close destroys the idle pengines in this pool.
+// Pengines in use are destroyed when they are returned.
+func (pool *EnginePool) Close() error {
+	pool.mu.Lock()
+	pool.closed = true
+	pool.mu.Unlock()
+
+	var err error
+	for {
+		select {
+		case eng := <-pool.idle:
+			if cerr := eng.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			pool.vacate()
+		default:
+			return err
+		}
+	}
+}
+
+func (pool *EnginePool) discard(eng *Engine) {
+	_ = eng.Close()
+	pool.vacate()
+}
+
+// vacate gives up an open engine's slot.
+// It doesn't block, in case a foreign engine was put in the pool.
+func (pool *EnginePool) vacate() {
+	select {
+	case <-pool.open:
+	default:
+	}
+}
+
+func (pool *EnginePool) isClosed() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.closed
+}
+
+// pooled is a query using a pengine from an EnginePool.
+type pooled[T any] struct {
+	Answers[T]
+	pool *EnginePool
+	once sync.Once
+}
+
+func (as *pooled[T]) Next(ctx context.Context) bool {
+	if as.Answers.Next(ctx) {
+		return true
+	}
+	// keep the pengine while waiting for Engine.Respond
+	if as.Answers.Err() != ErrPrompt {
+		as.release()
+	}
+	return false
+}
+
+func (as *pooled[T]) Close() error {
+	err := as.Answers.Close()
+	as.release()
+	return err
+}
+
+func (as *pooled[T]) release() {
+	as.once.Do(func() {
+		as.pool.Put(as.Engine())
+	})
+}
@@ -0,0 +1,212 @@
+package pengine
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestEnginePool(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	// fake server has a slave_limit of 3
+	pool, err := NewEnginePool(ctx, srv.client(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Size() != 3 {
+		t.Error("pool size should be limited by server. want: 3 got:", pool.Size())
+	}
+	if n := srv.alive(); n != 3 {
+		t.Error("want 3 pengines, got:", n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			as, err := pool.Ask(ctx, "between(1,5,X)")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := 0
+			for as.Next(ctx) {
+				n++
+			}
+			if err := as.Err(); err != nil {
+				t.Error(err)
+			}
+			if n != 5 {
+				t.Error("answer len mismatch. want: 5 got:", n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := srv.alive(); n != 3 {
+		t.Error("want 3 pengines, got:", n)
+	}
+
+	t.Run("replace dead", func(t *testing.T) {
+		eng, err := pool.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// kill it behind the pool's back
+		srv.mu.Lock()
+		delete(srv.pengines, eng.ID())
+		srv.mu.Unlock()
+		pool.Put(eng)
+
+		for i := 0; i < pool.Size(); i++ {
+			eng, err := pool.Get(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Put(eng)
+			if err := eng.Ping(ctx); err != nil {
+				t.Error("got unhealthy engine:", err)
+			}
+		}
+	})
+
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.alive(); n != 0 {
+		t.Error("pengines left alive:", n)
+	}
+	if _, err := pool.Get(ctx); err != ErrPoolClosed {
+		t.Error("want:", ErrPoolClosed, "got:", err)
+	}
+}
+
+func TestEnginePoolPrompt(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	pool, err := NewEnginePool(ctx, srv.client(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	as, err := pool.Ask(ctx, "pengine_input(continue, _), between(1,2,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.Next(ctx) {
+		t.Fatal("unexpected answer before prompt:", as.Current())
+	}
+	if err := as.Err(); err != ErrPrompt {
+		t.Fatal("want:", ErrPrompt, "got:", err)
+	}
+	// the pengine must stay checked out and alive while the prompt is pending
+	if n := srv.alive(); n != 1 {
+		t.Fatal("want 1 pengine, got:", n)
+	}
+	if err := as.Engine().Respond(ctx, engine.Atom("yes")); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for as.Next(ctx) {
+		n++
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("want 2 answers, got:", n)
+	}
+
+	// returned to the pool afterwards
+	eng, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eng != as.Engine() {
+		t.Error("pengine was not returned to the pool")
+	}
+	pool.Put(eng)
+}
+
+func TestEnginePoolPut(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	pool, err := NewEnginePool(ctx, srv.client(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := srv.client().Create(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.Put(eng)
+		pool.Put(eng)     // put twice
+		pool.Put(foreign) // not from this pool
+		pool.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock")
+	}
+	if n := srv.alive(); n != 0 {
+		t.Error("pengines left alive:", n)
+	}
+}
+
+func TestEnginePoolSource(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	c := srv.client()
+	c.SourceText = "big_source(1).\n"
+	c.Application = "app"
+
+	pool, err := NewEnginePool(ctx, c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	for i := 0; i < 2; i++ {
+		as, err := pool.Ask(ctx, "between(1,2,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for as.Next(ctx) {
+		}
+		if err := as.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.source != c.SourceText {
+		t.Errorf("source not uploaded at creation. got: %q", srv.source)
+	}
+	if len(srv.sends) == 0 {
+		t.Fatal("no send requests")
+	}
+	for _, body := range srv.sends {
+		if strings.Contains(body, "src_text") || strings.Contains(body, "application") {
+			t.Error("send request re-uploads create options:", body)
+		}
+	}
+}
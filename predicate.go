@@ -21,7 +21,9 @@ func RPCWithInterpreter(p *prolog.Interpreter) func(url, query, options engine.T
 // RPC is like pengine_rpc/3 from SWI, provided for as a native predicate for ichiban/prolog.
 // This is a native predicate for Prolog. To use the API from Go, use AskProlog.
 //
-// Supports the following options: application(Atom), chunk(Integer), src_text(Atom), src_url(Atom), debug(Boolean).
+// Supports the following options: application(Atom), chunk(Integer), src_text(Atom), src_url(Atom), debug(Boolean),
+// authorization(basic(User, Password)), authorization(digest(User, Password)), authorization(bearer(Token)).
+// The debug(true) option logs requests, responses, and events to standard error.
 //
 // See: https://www.swi-prolog.org/pldoc/man?predicate=pengine_rpc/3
 func RPC(url, query, options engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
@@ -89,6 +91,12 @@ func rpc(p *prolog.Interpreter) func(url, query, options engine.Term, k func(*en
 						return engine.Error(engine.TypeError(engine.ValidTypeAtom, x.Arg(0), env))
 					}
 					client.Debug = str == "true"
+				case "authorization":
+					auth, err := authOption(x.Arg(0), env)
+					if err != nil {
+						return engine.Error(err)
+					}
+					client.Auth = auth
 				}
 			}
 		}
@@ -96,7 +104,7 @@ func rpc(p *prolog.Interpreter) func(url, query, options engine.Term, k func(*en
 			return engine.Error(err)
 		}
 
-		as, err := client.createProlog(context.Background(), q.String())
+		as, err := client.createProlog(context.Background(), q.String(), "", true)
 		if err != nil {
 			return engine.Error(err)
 		}
@@ -105,6 +113,38 @@ func rpc(p *prolog.Interpreter) func(url, query, options engine.Term, k func(*en
 	}
 }
 
+// authOption parses the argument of an authorization option: basic(User, Password), digest(User, Password), or bearer(Token).
+func authOption(t engine.Term, env *engine.Env) (Authenticator, error) {
+	resolved := env.Resolve(t)
+	switch t := resolved.(type) {
+	case engine.Variable:
+		return nil, engine.InstantiationError(env)
+	case engine.Compound:
+		switch {
+		case (t.Functor() == "basic" || t.Functor() == "digest") && t.Arity() == 2:
+			user, pass := env.Resolve(t.Arg(0)), env.Resolve(t.Arg(1))
+			if _, ok := user.(engine.Atom); !ok {
+				return nil, engine.TypeError(engine.ValidTypeAtom, user, env)
+			}
+			if _, ok := pass.(engine.Atom); !ok {
+				return nil, engine.TypeError(engine.ValidTypeAtom, pass, env)
+			}
+			if t.Functor() == "digest" {
+				return &DigestAuth{Username: term2str(user, env), Password: term2str(pass, env)}, nil
+			}
+			return BasicAuth{Username: term2str(user, env), Password: term2str(pass, env)}, nil
+		case t.Functor() == "bearer" && t.Arity() == 1:
+			token := env.Resolve(t.Arg(0))
+			if _, ok := token.(engine.Atom); !ok {
+				return nil, engine.TypeError(engine.ValidTypeAtom, token, env)
+			}
+			return BearerAuth{Source: StaticToken(term2str(token, env))}, nil
+		}
+	}
+	domainError := engine.Atom("domain_error").Apply(engine.Atom("authorization"), resolved)
+	return nil, engine.NewException(engine.Atom("error").Apply(domainError, engine.NewVariable()), env)
+}
+
 func doRPC(as *prologAnswers, query engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
 	var done bool
 	return engine.Delay(func(ctx context.Context) *engine.Promise {
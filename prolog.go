@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/ichiban/prolog/engine"
@@ -15,31 +16,81 @@ import (
 //	between(1,3,X)
 //
 // Because ichiban/prolog is used to interpret results, using SWI's nonstandard syntax extensions like dictionaries may break it.
-func (e *Engine) AskProlog(ctx context.Context, query string) (Answers[engine.Term], error) {
-	as := newProlog(e)
-	opts := e.client.options("prolog")
-	query = "ask((" + query + "), " + opts.String() + ")"
-	a, err := e.sendProlog(ctx, query)
+// See the Ask function for placeholders.
+//
+// Returns ErrBusy if another query is running.
+func (e *Engine) AskProlog(ctx context.Context, query string, args ...any) (Answers[engine.Term], error) {
+	query, err := e.client.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return e.askProlog(ctx, query, "")
+}
+
+// AskPrologBindings is like AskProlog, but each answer is a map of the query's variable names to their values.
+// See the AskPrologBindings function for details.
+func (e *Engine) AskPrologBindings(ctx context.Context, query string, args ...any) (Answers[map[string]engine.Term], error) {
+	query, err := e.client.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	template, err := e.client.bindingsTemplate(query)
+	if err != nil {
+		return nil, err
+	}
+	as, err := e.askProlog(ctx, query, template)
+	if err != nil {
+		return nil, err
+	}
+	return convert(as, bindingsMap), nil
+}
+
+func (e *Engine) askProlog(ctx context.Context, query, template string) (*prologAnswers, error) {
+	as := newProlog(ctx, e)
+	as.query = query
+	if err := e.claim(as); err != nil {
+		return nil, err
+	}
+	opts := e.client.askOptions()
+	opts.Destroy = e.destroy
+	opts.Template = template
+	a, err := e.sendProlog(ctx, &as.stats, "ask(("+query+"), "+opts.String()+")")
 	if err != nil {
+		e.release(as)
 		return nil, err
 	}
-	return as, as.handle(ctx, a)
+	return as, as.start(ctx, a)
 }
 
-func newProlog(eng *Engine) *prologAnswers {
+func newProlog(ctx context.Context, eng *Engine) *prologAnswers {
 	p := &prologAnswers{
 		iterator: iterator[engine.Term]{
-			eng: eng,
+			eng:      eng,
+			onOutput: outputFrom(ctx),
+			onPrompt: promptFrom(ctx),
 		},
 	}
+	p.self = p
 	return p
 }
 
 type prologAnswers struct {
 	iterator[engine.Term]
+	query string
+}
+
+// start handles the first event of this query.
+func (p *prologAnswers) start(ctx context.Context, a string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.settle()
+	return p.handle(ctx, a)
 }
 
 func (as *prologAnswers) Next(ctx context.Context) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
 more:
 	switch {
 	case as.err != nil:
@@ -50,12 +101,33 @@ more:
 	case len(as.buf) > 0:
 		as.cur = as.pop()
 		return true
-	case as.more:
-		a, err := as.eng.sendProlog(ctx, "next")
+	case as.prompt != nil:
+		if err := as.answerPrompt(ctx); err != nil {
+			as.err = err
+			return false
+		}
+		goto more
+	case as.pull:
+		as.pull = false
+		a, err := as.eng.getProlog(ctx, &as.stats, "pull_response")
 		if err != nil {
+			as.err = as.canceled(ctx, err)
+			return false
+		}
+		if err := as.handle(ctx, a); err != nil {
 			as.err = err
 			return false
 		}
+		goto more
+	case as.more && as.eng.isDead():
+		as.err = ErrDead
+		return false
+	case as.more:
+		a, err := as.eng.sendProlog(ctx, &as.stats, "next")
+		if err != nil {
+			as.err = as.canceled(ctx, err)
+			return false
+		}
 		if err := as.handle(ctx, a); err != nil {
 			as.err = err
 			return false
@@ -65,35 +137,68 @@ more:
 	return false
 }
 
-func (c Client) createProlog(ctx context.Context, query string) (*prologAnswers, error) {
+// Close stops this query. It is not necessary to call this if all results have been iterated through.
+func (p *prologAnswers) Close() error {
+	if p == nil || p.eng == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.settle()
+	if p.eng.isDead() || !p.running() {
+		return nil
+	}
+	ctx := context.Background()
+	a, err := p.eng.sendProlog(ctx, &p.stats, "stop")
+	if err != nil {
+		return err
+	}
+	return p.handle(ctx, a)
+}
+
+// createProlog creates a Prolog format pengine, asking query if it is not empty.
+// If template is empty, each answer is the instantiated query.
+func (c Client) createProlog(ctx context.Context, query, template string, destroy bool) (*prologAnswers, error) {
 	if c.URL == "" {
 		return nil, fmt.Errorf("pengine: Server URL not set")
 	}
 
 	eng := &Engine{
 		client:  c,
-		destroy: true,
-		debug:   c.Debug,
+		destroy: destroy,
+		log:     c.logger(),
+		prolog:  true,
 	}
-	as := newProlog(eng)
+	as := newProlog(ctx, eng)
 	opts := c.options("prolog")
-	opts.Destroy = true
-	opts.Ask = query
-	opts.Template = query
+	opts.Destroy = destroy
+	var stats *queryStats // nil unless creating with a query
+	if query != "" {
+		stats = &as.stats
+		as.query = query
+		if err := eng.claim(as); err != nil {
+			return nil, err
+		}
+		opts.Ask = query
+		opts.Template = query
+		if template != "" {
+			opts.Template = template
+		}
+	}
 
-	evt, err := eng.postProlog("create", opts)
+	var evt string
+	err := c.retryCreate(ctx, query, func() (err error) {
+		evt, err = eng.postProlog(ctx, stats, "create", opts)
+		return
+	})
 	if err != nil {
 		return nil, fmt.Errorf("pengine create error: %w", err)
 	}
-	return as, as.handle(ctx, evt)
+	return as, as.start(ctx, evt)
 }
 
 func (p *prologAnswers) handle(ctx context.Context, a string) error {
-	interpreter := defaultInterpreter
-	if p.eng.client.Interpreter != nil {
-		interpreter = p.eng.client.Interpreter
-	}
-	parser := interpreter.Parser(strings.NewReader(a), nil)
+	parser := p.eng.client.interpreter().Parser(strings.NewReader(a), nil)
 	t, err := parser.Term()
 	if err != nil {
 		return fmt.Errorf("pengines: failed to parse response: %w", err)
@@ -138,6 +243,9 @@ func (p *prologAnswers) handleEvent(t engine.Compound) error {
 			'$pengine_output'(ID, Term).
 
 	*/
+	if t.Functor() != "success" {
+		p.eng.logEvent(string(t.Functor()))
+	}
 	switch t.Functor() {
 	case "success": // success/5
 		// id, results, projection, time, more
@@ -151,14 +259,27 @@ func (p *prologAnswers) handleEvent(t engine.Compound) error {
 	case "create": // create/2
 		// id, list
 		return p.onCreate(t.Arg(0), t.Arg(1))
-	case "destroy": // destroy/2
+	case "destroy": // destroy/1, destroy/2
 		// id, event
+		if t.Arity() == 1 {
+			return p.onDestroy(t.Arg(0), nil)
+		}
 		return p.onDestroy(t.Arg(0), t.Arg(1))
+	case "stop": // stop/1
+		// id
+		return p.onStop(t.Arg(0))
+	case "died": // died/1
+		// id
+		return p.onDied(t.Arg(0))
 	case "output": // output/2
-		// TODO: unimplemented
+		// id, term
 		return p.onOutput(t.Arg(0), t.Arg(1))
 	case "prompt": // prompt/2
-		// TODO
+		// id, term
+		return p.onPrompt(t.Arg(0), t.Arg(1))
+	case "abort": // abort/1
+		// id
+		p.err = ErrAborted
 	}
 	return nil
 }
@@ -170,11 +291,13 @@ func (p *prologAnswers) accumulate(time engine.Term) bool {
 }
 
 func (p *prologAnswers) onSuccess(id, results, projection, time, more engine.Term) error {
+	var n int
 	iter := engine.ListIterator{List: results, Env: nil}
 	for iter.Next() {
 		cur := resolve(iter.Current(), nil, nil)
 		p.buf = append(p.buf, cur)
 		p.good++
+		n++
 	}
 	if err := iter.Err(); err != nil {
 		return err
@@ -187,23 +310,73 @@ func (p *prologAnswers) onSuccess(id, results, projection, time, more engine.Ter
 		return engine.TypeError(engine.ValidTypeAtom, more, nil)
 	}
 	p.more = m == "true"
+	p.eng.logEvent("success", slog.Int("solutions", n), slog.Bool("more", p.more))
+	seconds, _ := time.(engine.Float)
+	p.stats.chunk(n, float64(seconds))
 
 	return nil
 }
 
 func (p *prologAnswers) onFailure(id, time engine.Term) error {
 	p.bad++
+	p.more = false
 	p.accumulate(time)
 	return nil
 }
 
 func (p *prologAnswers) onError(id, ball engine.Term) error {
-	p.err = engine.NewException(ball, nil)
+	if isAborted(ball) {
+		p.err = ErrAborted
+		return nil
+	}
+	ex := &Exception{
+		Ball:  resolve(ball, nil, nil),
+		ID:    p.eng.ID(),
+		URL:   p.eng.client.URL,
+		Query: p.query,
+	}
+	if atomID, ok := id.(engine.Atom); ok {
+		ex.ID = string(atomID)
+	}
+	p.err = ex
 	return nil
 }
 
 func (p *prologAnswers) onOutput(id, term engine.Term) error {
-	// TODO(guregu): currently unimplemented.
+	out := Output{
+		Prolog: resolve(term, nil, nil),
+	}
+	if atomID, ok := id.(engine.Atom); ok {
+		out.ID = string(atomID)
+	}
+	p.output(out)
+	p.pull = true
+	return nil
+}
+
+func (p *prologAnswers) onPrompt(id, term engine.Term) error {
+	prompt := Prompt{
+		Prolog: resolve(term, nil, nil),
+	}
+	if atomID, ok := id.(engine.Atom); ok {
+		prompt.ID = string(atomID)
+	}
+	p.prompt = &prompt
+	return nil
+}
+
+func (p *prologAnswers) onStop(id engine.Term) error {
+	p.more = false
+	if p.eng.destroy {
+		defer p.eng.die()
+	}
+	return nil
+}
+
+func (p *prologAnswers) onDied(id engine.Term) error {
+	p.eng.die()
+	p.more = false
+	p.err = ErrDead
 	return nil
 }
 
@@ -221,8 +394,7 @@ func (p *prologAnswers) onCreate(id, data engine.Term) error {
 	if !ok {
 		return fmt.Errorf("expected atom ID: got %T (value: %v)", id, id)
 	}
-	p.eng.id = string(atomID)
-
+	var openLimit int
 	iter := engine.ListIterator{List: data}
 	for iter.Next() {
 		cur := iter.Current()
@@ -232,7 +404,7 @@ func (p *prologAnswers) onCreate(id, data engine.Term) error {
 			case "slave_limit":
 				n, ok := t.Arg(0).(engine.Integer)
 				if ok {
-					p.eng.openLimit = int(n)
+					openLimit = int(n)
 				}
 			case "answer":
 				goal, ok := t.Arg(0).(engine.Compound)
@@ -242,6 +414,7 @@ func (p *prologAnswers) onCreate(id, data engine.Term) error {
 			}
 		}
 	}
+	p.eng.created(string(atomID), openLimit)
 	return iter.Err()
 }
 
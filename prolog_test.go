@@ -2,8 +2,14 @@ package pengine
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ichiban/prolog"
@@ -42,6 +48,204 @@ func TestProlog(t *testing.T) {
 	}
 }
 
+func TestPrologPersistent(t *testing.T) {
+	ctx := context.Background()
+	eng, err := Client{
+		URL:        *penginesServerURL,
+		SourceText: "fruit(apple).\nfruit(banana).\n",
+		Debug:      true,
+	}.CreateProlog(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		as, err := eng.AskProlog(ctx, "fruit(X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for as.Next(ctx) {
+			n++
+		}
+		if err := as.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Error("answer len mismatch. want: 2 got:", n)
+		}
+	}
+
+	if err := eng.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.AskProlog(ctx, "fruit(X)"); err != ErrDead {
+		t.Error("want:", ErrDead, "got:", err)
+	}
+}
+
+func TestCreatePrologSource(t *testing.T) {
+	// minimal Prolog-format server recording what each request uploads
+	var mu sync.Mutex
+	var creates, sends []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "text/x-prolog; charset=UTF-8")
+		switch r.URL.Path {
+		case "/create":
+			creates = append(creates, string(body))
+			io.WriteString(w, "create('1',[slave_limit(3)]).\n")
+		case "/send":
+			sends = append(sends, string(body))
+			io.WriteString(w, "success('1',[fact(1)],[],0.001,false).\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	eng, err := Client{URL: srv.URL, SourceText: "fact(1).\n"}.CreateProlog(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		as, err := eng.AskProlog(ctx, "fact(X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for as.Next(ctx) {
+		}
+		if err := as.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(creates) != 1 || !strings.Contains(creates[0], "src_text") {
+		t.Error("source should be uploaded once at creation:", creates)
+	}
+	if len(sends) != 2 {
+		t.Fatal("want 2 sends, got:", sends)
+	}
+	for _, body := range sends {
+		if strings.Contains(body, "src_text") {
+			t.Error("ask re-uploads the source:", body)
+		}
+	}
+}
+
+func TestPrologClose(t *testing.T) {
+	ctx := context.Background()
+	eng, err := Client{
+		URL:   *penginesServerURL,
+		Debug: true,
+	}.CreateProlog(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as, err := eng.AskProlog(ctx, "between(1, 10, X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !as.Next(ctx) {
+		t.Fatal("no answer:", as.Err())
+	}
+	// stop the query early, the engine should stay alive
+	if err := as.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Ping(ctx); err != nil {
+		t.Fatal("engine died after stop:", err)
+	}
+
+	if err := eng.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Ping(ctx); err != ErrDead {
+		t.Error("want:", ErrDead, "got:", err)
+	}
+}
+
+func TestPrologException(t *testing.T) {
+	ctx := context.Background()
+	client := Client{
+		URL:   *penginesServerURL,
+		Debug: true,
+	}
+	as, err := AskProlog(ctx, client, "this_predicate_does_not_exist(X)")
+	if err == nil {
+		for as.Next(ctx) {
+		}
+		err = as.Err()
+	}
+	if !errors.Is(err, KindExistence) {
+		t.Error("want existence error, got:", err)
+	}
+	var ex *Exception
+	if !errors.As(err, &ex) {
+		t.Fatal("want *Exception, got:", err)
+	}
+	if ex.URL != client.URL {
+		t.Error("unexpected URL:", ex.URL)
+	}
+}
+
+func TestPrologOutput(t *testing.T) {
+	var got []engine.Term
+	client := Client{
+		URL:   *penginesServerURL,
+		Debug: true,
+		OnOutput: func(out Output) {
+			got = append(got, out.Prolog)
+		},
+	}
+
+	ctx := context.Background()
+	as, err := AskProlog(ctx, client, "pengine_output(hello(world)), X = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for as.Next(ctx) {
+		t.Logf("answer: %+v", as.Current())
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []engine.Term{engine.Atom("hello").Apply(engine.Atom("world"))}
+	if !reflect.DeepEqual(want, got) {
+		t.Error("unexpected output. want:", want, "got:", got)
+	}
+}
+
+func TestPrologPrompt(t *testing.T) {
+	client := Client{
+		URL:   *penginesServerURL,
+		Debug: true,
+		OnPrompt: func(ctx context.Context, prompt Prompt) (engine.Term, error) {
+			return engine.Atom("answer").Apply(prompt.Prolog), nil
+		},
+	}
+
+	ctx := context.Background()
+	as, err := AskProlog(ctx, client, "pengine_input(question, X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !as.Next(ctx) {
+		t.Fatal("no answer:", as.Err())
+	}
+	want := engine.Atom("pengine_input").Apply(engine.Atom("question"), engine.Atom("answer").Apply(engine.Atom("question")))
+	if got := as.Current(); !reflect.DeepEqual(want, got) {
+		t.Error("unexpected answer. want:", want, "got:", got)
+	}
+}
+
 func TestRPC(t *testing.T) {
 	p := prolog.New(nil, os.Stdout)
 	p.Register3("pengine_rpc", RPC)
@@ -125,3 +329,51 @@ func TestRPC(t *testing.T) {
 		}
 	})
 }
+
+func TestAskPrologInto(t *testing.T) {
+	ctx := context.Background()
+	client := Client{URL: *penginesServerURL}
+
+	type row struct {
+		X    int
+		Name string `prolog:"N"`
+	}
+	as, err := AskPrologInto[row](ctx, client, "member(X-N, [1-a, 2-b])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []row
+	for as.Next(ctx) {
+		got = append(got, as.Current())
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []row{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(got, want) {
+		t.Error("want:", want, "got:", got)
+	}
+}
+
+func TestAskPrologBindings(t *testing.T) {
+	ctx := context.Background()
+	client := Client{URL: *penginesServerURL}
+
+	as, err := AskPrologBindings(ctx, client, "between(1,3,X), Y is X*X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n engine.Integer
+	for as.Next(ctx) {
+		n++
+		cur := as.Current()
+		if cur["X"] != n || cur["Y"] != n*n {
+			t.Error("unexpected bindings:", cur)
+		}
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Error("want 3 answers, got:", n)
+	}
+}
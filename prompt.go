@@ -0,0 +1,121 @@
+package pengine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// Prompt is a request for input sent by a remote goal calling pengine_input/2.
+type Prompt struct {
+	// ID is the ID of the pengine that sent this prompt.
+	ID string
+	// Data is the prompt term, set when using the JSON format.
+	Data Term
+	// Prolog is the prompt term, set when using the Prolog format.
+	Prolog engine.Term
+}
+
+// PromptHandler returns the reply to a prompt.
+// The reply is unified with the second argument of pengine_input/2.
+type PromptHandler func(ctx context.Context, prompt Prompt) (reply engine.Term, err error)
+
+type promptKey struct{}
+
+// WithPrompt returns a context that will use fn to reply to pengine_input/2 prompts
+// of queries started with it. This takes precedence over Client.OnPrompt.
+func WithPrompt(ctx context.Context, fn PromptHandler) context.Context {
+	return context.WithValue(ctx, promptKey{}, fn)
+}
+
+func promptFrom(ctx context.Context) PromptHandler {
+	fn, _ := ctx.Value(promptKey{}).(PromptHandler)
+	return fn
+}
+
+// responder is a running query, which can reply to prompts.
+type responder interface {
+	// respond replies to a pending prompt, returning false if there is none.
+	respond(ctx context.Context, input string) (bool, error)
+	// reply sends input to the pengine and handles the resulting event.
+	// The caller must hold the query's lock.
+	reply(ctx context.Context, input string) error
+}
+
+// Respond replies to the current query's pending prompt.
+// It is only necessary to call this when no prompt handler is configured,
+// in which case Answers.Next will stop and Answers.Err will return ErrPrompt.
+// After responding, iteration can be resumed.
+//
+// Prompts received from Engine.Events can also be answered with this,
+// in which case the following events are delivered to the Events channel.
+func (e *Engine) Respond(ctx context.Context, reply engine.Term) error {
+	e.mu.Lock()
+	dead, query, stream := e.dead, e.query, e.stream
+	e.mu.Unlock()
+	if dead {
+		return ErrDead
+	}
+	input := promptInput(reply)
+	// a prompt received by Events is answered by its poller
+	if stream != nil && stream.respond(input) {
+		return nil
+	}
+	if query != nil {
+		if ok, err := query.respond(ctx, input); ok {
+			return err
+		}
+	}
+	return fmt.Errorf("pengine: no pending prompt")
+}
+
+func promptInput(reply engine.Term) string {
+	return "input(" + stringify(reply) + ")"
+}
+
+func (as *iterator[T]) answerPrompt(ctx context.Context) error {
+	handler := as.onPrompt
+	if handler == nil {
+		handler = as.eng.client.OnPrompt
+	}
+	if handler == nil {
+		return ErrPrompt
+	}
+	reply, err := handler(ctx, *as.prompt)
+	if err != nil {
+		return err
+	}
+	as.prompt = nil
+	return as.self.reply(ctx, promptInput(reply))
+}
+
+func (as *iterator[T]) respond(ctx context.Context, input string) (bool, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	defer as.settle()
+	if as.prompt == nil {
+		return false, nil
+	}
+	as.prompt = nil
+	if as.err == ErrPrompt {
+		as.err = nil
+	}
+	return true, as.self.reply(ctx, input)
+}
+
+func (as *iterator[T]) reply(ctx context.Context, input string) error {
+	a, err := as.eng.send(ctx, &as.stats, input)
+	if err != nil {
+		return err
+	}
+	return as.handle(a)
+}
+
+func (p *prologAnswers) reply(ctx context.Context, input string) error {
+	a, err := p.eng.sendProlog(ctx, &p.stats, input)
+	if err != nil {
+		return err
+	}
+	return p.handle(ctx, a)
+}
@@ -0,0 +1,118 @@
+package pengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.
+//
+// Only idempotent operations are retried: creating a pengine, Engine.Ping, and Engine.Abort.
+// Requests that advance a query, such as fetching the next answer, are never retried
+// because there is no way to know whether the server already advanced.
+// Creating a pengine that also runs a query, as Client.Ask does, is only retried if RetryQueries is set,
+// because the server might have already run the query.
+// Note that if the connection is lost after the server received a create request,
+// retrying may leave an orphaned pengine on the server until it times out.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values less than 2 disable retrying.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It doubles for each subsequent retry.
+	// 100ms by default.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay between retries. 5s by default.
+	MaxBackoff time.Duration
+	// Retryable returns true if the request that failed with err should be retried.
+	// If nil, DefaultRetryable is used.
+	Retryable func(err error) bool
+	// RetryQueries enables retrying creates that run a query, such as Client.Ask.
+	// Only set this if your queries are safe to run more than once.
+	RetryQueries bool
+}
+
+// DefaultRetryable returns true for transient errors:
+// connection resets and refusals, unexpected EOFs, network timeouts, and HTTPErrors with status 429, 502, 503, or 504.
+// Other transport errors such as TLS certificate errors, DNS lookup failures, and bad URLs are permanent.
+// Errors sent by pengines such as Error and Exception, and context errors, are not retryable.
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var herr *HTTPError
+	if errors.As(err, &herr) {
+		switch herr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+func (policy *RetryPolicy) retryable(err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// backoff returns the delay before the given retry (starting from 1).
+func (policy *RetryPolicy) backoff(retry int) time.Duration {
+	min, max := policy.MinBackoff, policy.MaxBackoff
+	if min <= 0 {
+		min = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+	delay := min
+	for i := 1; i < retry && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// retry calls fn, retrying according to the client's RetryPolicy.
+// fn must be idempotent.
+func (c Client) retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	policy := c.Retry
+	if policy == nil {
+		return err
+	}
+	for attempt := 1; err != nil && attempt < policy.MaxAttempts && policy.retryable(err); attempt++ {
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return err
+}
+
+// retryCreate is like retry, but doesn't retry creates that run a query unless the policy allows it.
+func (c Client) retryCreate(ctx context.Context, query string, fn func() error) error {
+	if query != "" && (c.Retry == nil || !c.Retry.RetryQueries) {
+		return fn()
+	}
+	return c.retry(ctx, fn)
+}
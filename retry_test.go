@@ -0,0 +1,167 @@
+package pengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	c := srv.client()
+	c.Retry = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}
+
+	t.Run("create", func(t *testing.T) {
+		srv.failNext(2)
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+
+		srv.failNext(1)
+		if err := eng.Ping(ctx); err != nil {
+			t.Error("ping should be retried:", err)
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		srv.failNext(3)
+		_, err := c.Create(ctx, false)
+		var herr *HTTPError
+		if !errors.As(err, &herr) || herr.StatusCode != http.StatusServiceUnavailable {
+			t.Error("want 503 HTTPError, got:", err)
+		}
+	})
+
+	t.Run("create with query", func(t *testing.T) {
+		srv.failNext(1)
+		before := srv.requestCount()
+		if _, err := c.Ask(ctx, "between(1,3,X)"); err == nil {
+			t.Error("create with a query should not be retried")
+		}
+		if n := srv.requestCount() - before; n != 1 {
+			t.Error("want 1 request, got:", n)
+		}
+
+		c := c
+		c.Retry = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, RetryQueries: true}
+		srv.failNext(1)
+		as, err := c.Ask(ctx, "between(1,3,X)")
+		if err != nil {
+			t.Fatal("create with a query should be retried with RetryQueries:", err)
+		}
+		defer as.Close()
+		if !as.Next(ctx) {
+			t.Error("no answer:", as.Err())
+		}
+	})
+
+	t.Run("next", func(t *testing.T) {
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		as, err := eng.Ask(ctx, "between(1,3,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !as.Next(ctx) {
+			t.Fatal("no first answer:", as.Err())
+		}
+
+		srv.failNext(1)
+		before := srv.requestCount()
+		if as.Next(ctx) {
+			t.Error("next should not be retried")
+		}
+		if n := srv.requestCount() - before; n != 1 {
+			t.Error("want 1 request, got:", n)
+		}
+		var herr *HTTPError
+		if !errors.As(as.Err(), &herr) {
+			t.Error("want HTTPError, got:", as.Err())
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		c := c
+		c.Retry = &RetryPolicy{
+			MaxAttempts: 3,
+			MinBackoff:  time.Millisecond,
+			Retryable:   func(error) bool { return false },
+		}
+		srv.failNext(1)
+		before := srv.requestCount()
+		if _, err := c.Create(ctx, false); err == nil {
+			t.Error("expected error")
+		}
+		if n := srv.requestCount() - before; n != 1 {
+			t.Error("want 1 request, got:", n)
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+}
+
+// countingTransport counts round trips.
+type countingTransport struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.mu.Lock()
+	ct.n++
+	ct.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetryPermanent(t *testing.T) {
+	// self-signed certificate that the default transport doesn't trust
+	tlsSrv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsSrv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+
+	tests := map[string]string{
+		"x509":       tlsSrv.URL,
+		"bad scheme": "gopher://localhost/pengine",
+	}
+	for name, url := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := new(countingTransport)
+			c := Client{
+				URL:   url,
+				HTTP:  &http.Client{Transport: transport},
+				Retry: &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond},
+			}
+			_, err := c.Create(context.Background(), false)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if DefaultRetryable(err) {
+				t.Error("error should not be retryable:", err)
+			}
+			if transport.n != 1 {
+				t.Error("want 1 attempt, got:", transport.n)
+			}
+		})
+	}
+}
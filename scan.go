@@ -0,0 +1,645 @@
+package pengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+// AskInto is like Ask, but scans each Solution into a value of type T using Solution.Scan.
+// T must be a struct or a map with string keys.
+//
+//	type row struct {
+//		Name string `pengine:"N"`
+//		Age  int    `pengine:"A"`
+//	}
+//	answers, err := pengine.AskInto[row](ctx, client, "person(N, A)")
+func AskInto[T any](ctx context.Context, c Client, query string, args ...any) (Answers[T], error) {
+	as, err := c.Ask(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return convert(as, func(sol Solution) (T, error) {
+		var v T
+		err := sol.Scan(&v)
+		return v, err
+	}), nil
+}
+
+// Scan copies the values of this solution's variables into dst,
+// which must be a pointer to a struct or to a map with string keys.
+//
+// Variables are matched to struct fields by the field's `pengine:"Name"` tag, or by the field's name if untagged,
+// preferring an exact match over a case-insensitive one. The fields of embedded structs are matched as if they were
+// in the outer struct, unless the embedded field is tagged; fields of the outer struct take precedence.
+// Fields tagged `pengine:"-"` are ignored. Fields whose variable is missing from the solution are left untouched.
+// See Term.Scan for how values are converted.
+func (sol Solution) Scan(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("pengine: Scan destination must be a non-nil pointer, got %T", dst)
+	}
+	v = v.Elem()
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			key, ok := findKey(sol, f.name)
+			if !ok {
+				continue
+			}
+			if err := scanTerm(sol[key], v.FieldByIndex(f.index), key, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("pengine: can't Scan into %s: map keys must be strings", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(sol)))
+		}
+		for name, t := range sol {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := scanTerm(t, elem, name, name); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	}
+	return fmt.Errorf("pengine: can't Scan Solution into %s: must be a struct or map", v.Type())
+}
+
+// Scan converts this term into dst, which must be a non-nil pointer.
+// The following conversions are supported:
+//
+//   - atoms and strings to string; []byte
+//   - numbers to integers (checking for overflow), floats, *big.Int, and json.Number
+//   - true and false to bool
+//   - lists to slices and arrays
+//   - dicts to maps with string keys, and to structs (matching keys to fields like Solution.Scan)
+//   - compounds to structs, with arguments assigned to fields in order, or by tags like `pengine:",arg=1"` as with Marshal
+//   - any term to Term and engine.Term
+//   - any term to an empty interface, as string, int64, float64, *big.Int, bool, []any, map[string]any, or Compound
+//
+// Pointers are allocated as needed, and null is converted to a nil pointer.
+func (t Term) Scan(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("pengine: Scan destination must be a non-nil pointer, got %T", dst)
+	}
+	return scanTerm(t, v.Elem(), "", "")
+}
+
+// ScanError is returned when a term can't be converted to a Go value.
+type ScanError struct {
+	// Var is the name of the variable holding the term, if known.
+	Var string
+	// Path is the location of the offending term, such as X.Address[2].City.
+	Path string
+	// Type is the destination type.
+	Type reflect.Type
+	// Term is the offending term.
+	Term Term
+	// Reason explains why the conversion failed.
+	Reason string
+}
+
+func (err *ScanError) Error() string {
+	var where string
+	if err.Path != "" {
+		where = " " + err.Path
+	}
+	return fmt.Sprintf("pengine: can't scan%s (%s) into %s: %s", where, describe(err.Term), err.Type, err.Reason)
+}
+
+var (
+	termType       = reflect.TypeOf(Term{})
+	bigIntType     = reflect.TypeOf(big.Int{})
+	numberType     = reflect.TypeOf(json.Number(""))
+	prologTermType = reflect.TypeOf((*engine.Term)(nil)).Elem()
+	stringType     = reflect.TypeOf("")
+)
+
+func scanTerm(t Term, v reflect.Value, name, path string) error {
+	fail := func(reason string) error {
+		return &ScanError{Var: name, Path: path, Type: v.Type(), Term: t, Reason: reason}
+	}
+
+	switch v.Type() {
+	case termType:
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case prologTermType:
+		if pt := t.Prolog(); pt != nil {
+			v.Set(reflect.ValueOf(pt))
+		}
+		return nil
+	case numberType:
+		if t.Number == nil {
+			return fail("want number")
+		}
+		v.SetString(string(*t.Number))
+		return nil
+	case bigIntType:
+		n, ok := t.bigInt()
+		if !ok {
+			return fail("want integer")
+		}
+		v.Addr().Interface().(*big.Int).Set(n)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if t.Null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return scanTerm(t, v.Elem(), name, path)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fail("unsupported interface type")
+		}
+		if x := t.value(); x != nil {
+			v.Set(reflect.ValueOf(x))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	case reflect.String:
+		switch {
+		case t.Atom != nil:
+			v.SetString(*t.Atom)
+		case t.String != nil:
+			v.SetString(*t.String)
+		default:
+			return fail("want atom or string")
+		}
+		return nil
+	case reflect.Bool:
+		switch {
+		case t.Boolean != nil:
+			v.SetBool(*t.Boolean)
+		case t.Atom != nil && (*t.Atom == "true" || *t.Atom == "false"):
+			v.SetBool(*t.Atom == "true")
+		default:
+			return fail("want boolean")
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t.Number == nil {
+			return fail("want integer")
+		}
+		n, err := strconv.ParseInt(string(*t.Number), 10, 64)
+		if err != nil {
+			if _, ok := t.bigInt(); ok {
+				return fail("out of range")
+			}
+			return fail("want integer")
+		}
+		if v.OverflowInt(n) {
+			return fail("out of range")
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := t.bigInt()
+		if !ok {
+			return fail("want integer")
+		}
+		if n.Sign() < 0 {
+			return fail("want non-negative integer")
+		}
+		if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+			return fail("out of range")
+		}
+		v.SetUint(n.Uint64())
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if t.Number == nil {
+			return fail("want number")
+		}
+		f, err := strconv.ParseFloat(string(*t.Number), 64)
+		if err != nil || v.OverflowFloat(f) {
+			return fail("out of range")
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		switch {
+		case t.List != nil:
+		case t.Null:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		case v.Type().Elem().Kind() == reflect.Uint8 && t.Atom != nil:
+			v.SetBytes([]byte(*t.Atom))
+			return nil
+		case v.Type().Elem().Kind() == reflect.Uint8 && t.String != nil:
+			v.SetBytes([]byte(*t.String))
+			return nil
+		default:
+			return fail("want list")
+		}
+		s := reflect.MakeSlice(v.Type(), len(t.List), len(t.List))
+		for i, elem := range t.List {
+			if err := scanTerm(elem, s.Index(i), name, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		if t.List == nil {
+			return fail("want list")
+		}
+		if len(t.List) != v.Len() {
+			return fail(fmt.Sprintf("want list of length %d, got %d", v.Len(), len(t.List)))
+		}
+		for i, elem := range t.List {
+			if err := scanTerm(elem, v.Index(i), name, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if t.Dictionary == nil {
+			return fail("want dict")
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fail("map keys must be strings")
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(t.Dictionary)))
+		}
+		for key, val := range t.Dictionary {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := scanTerm(val, elem, name, path+"."+key); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		switch {
+		case t.Dictionary != nil:
+			for _, f := range fields {
+				key, ok := findKey(t.Dictionary, f.name)
+				if !ok {
+					continue
+				}
+				if err := scanTerm(t.Dictionary[key], v.FieldByIndex(f.index), name, path+"."+key); err != nil {
+					return err
+				}
+			}
+			return nil
+		case t.Compound != nil:
+			fields, err := argsOf(v.Type())
+			if err != nil {
+				return err
+			}
+			if len(t.Compound.Args) != len(fields) {
+				return fail(fmt.Sprintf("want compound of arity %d, got %d", len(fields), len(t.Compound.Args)))
+			}
+			for i, arg := range t.Compound.Args {
+				if err := scanTerm(arg, v.FieldByIndex(fields[i].index), name, path+"."+fields[i].name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fail("want dict or compound")
+	}
+	return fail("unsupported type")
+}
+
+// field is a struct field that terms can be scanned into.
+type field struct {
+	name  string
+	index []int
+	opt   string // tag option, such as string in `pengine:"Name,string"`
+	arg   string // argument position from the arg=N tag option, validated by argsOf
+}
+
+// fieldsOf returns the exported fields of a struct type, named by their pengine tag or field name.
+// The fields of untagged embedded structs are included in place of the embedded field,
+// unless the outer struct has a field with the same name.
+func fieldsOf(typ reflect.Type) []field {
+	var fields []field
+	var promoted [][]field
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("pengine") == "" {
+			inner := fieldsOf(f.Type)
+			for j := range inner {
+				inner[j].index = append([]int{i}, inner[j].index...)
+			}
+			promoted = append(promoted, inner)
+			fields = append(fields, field{index: []int{i}}) // placeholder, replaced below
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, opt, arg := f.Name, "", ""
+		if tag, ok := f.Tag.Lookup("pengine"); ok {
+			if tag == "-" {
+				continue
+			}
+			prefix, rest, _ := strings.Cut(tag, ",")
+			if prefix != "" {
+				name = prefix
+			}
+			for _, o := range strings.Split(rest, ",") {
+				if n, ok := strings.CutPrefix(o, "arg="); ok {
+					arg = n
+					continue
+				}
+				if o != "" {
+					opt = o
+				}
+			}
+		}
+		fields = append(fields, field{name: name, index: f.Index, opt: opt, arg: arg})
+	}
+	if len(promoted) == 0 {
+		return fields
+	}
+
+	outer := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if f.name != "" {
+			outer[f.name] = true
+		}
+	}
+	flat := make([]field, 0, len(fields))
+	for _, f := range fields {
+		if f.name != "" {
+			flat = append(flat, f)
+			continue
+		}
+		inner := promoted[0]
+		promoted = promoted[1:]
+		for _, g := range inner {
+			if !outer[g.name] {
+				flat = append(flat, g)
+			}
+		}
+	}
+	return flat
+}
+
+// findKey finds the key of m matching a field name, preferring an exact match over a case-insensitive one.
+// If several keys match case-insensitively, the first in sorted order is used.
+func findKey[V any](m map[string]V, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	var found string
+	for key := range m {
+		if strings.EqualFold(key, name) && (found == "" || key < found) {
+			found = key
+		}
+	}
+	return found, found != ""
+}
+
+// bigInt returns this term as an integer.
+func (t Term) bigInt() (*big.Int, bool) {
+	if t.Number == nil {
+		return nil, false
+	}
+	return new(big.Int).SetString(string(*t.Number), 10)
+}
+
+// value returns this term as a plain Go value.
+func (t Term) value() any {
+	switch {
+	case t.Atom != nil:
+		return *t.Atom
+	case t.String != nil:
+		return *t.String
+	case t.Number != nil:
+		if n, err := strconv.ParseInt(string(*t.Number), 10, 64); err == nil {
+			return n
+		}
+		if n, ok := t.bigInt(); ok {
+			return n
+		}
+		f, _ := strconv.ParseFloat(string(*t.Number), 64)
+		return f
+	case t.Boolean != nil:
+		return *t.Boolean
+	case t.List != nil:
+		list := make([]any, len(t.List))
+		for i, elem := range t.List {
+			list[i] = elem.value()
+		}
+		return list
+	case t.Dictionary != nil:
+		dict := make(map[string]any, len(t.Dictionary))
+		for k, v := range t.Dictionary {
+			dict[k] = v.value()
+		}
+		return dict
+	case t.Compound != nil:
+		return *t.Compound
+	}
+	return nil
+}
+
+// describe returns a short description of t for error messages.
+func describe(t Term) string {
+	switch {
+	case t.Atom != nil:
+		return "atom " + escapeAtom(*t.Atom)
+	case t.String != nil:
+		return "string " + strconv.Quote(*t.String)
+	case t.Number != nil:
+		return "number " + string(*t.Number)
+	case t.Boolean != nil:
+		return "boolean " + strconv.FormatBool(*t.Boolean)
+	case t.List != nil:
+		return fmt.Sprintf("list of length %d", len(t.List))
+	case t.Dictionary != nil:
+		return "dict"
+	case t.Compound != nil:
+		return fmt.Sprintf("compound %s/%d", escapeAtom(t.Compound.Functor), len(t.Compound.Args))
+	case t.Variable != nil:
+		return "variable " + *t.Variable
+	case t.Null:
+		return "null"
+	}
+	return "empty term"
+}
+
+// AskPrologInto is like AskProlog, but decodes each answer into a value of type T.
+//
+// Answers are variable bindings, as with AskPrologBindings. Depending on T, each answer is decoded as:
+//
+//   - struct: variables are matched to fields by their `prolog:"Name"` tag (or `pengine` tag) or field name,
+//     falling back to a case-insensitive match as with Solution.Scan
+//   - map with string keys: variable names to values
+//   - slice: values of the variables in order of appearance
+//
+// Values are converted with the same rules as ichiban/prolog's Solutions.Scan:
+//
+//   - any term, including unbound variables, to engine.Term and empty interfaces, as-is
+//   - integers to signed integer types, truncating values that are out of range like a Go conversion
+//   - floats to float types
+//   - atoms to string
+//   - lists to slices, converting each member with these rules
+//
+// Other conversions fail with a ScanError.
+func AskPrologInto[T any](ctx context.Context, c Client, query string, args ...any) (Answers[T], error) {
+	query, err := c.bind(query, args)
+	if err != nil {
+		return nil, err
+	}
+	template, err := c.bindingsTemplate(query)
+	if err != nil {
+		return nil, err
+	}
+	as, err := c.createProlog(ctx, query, template, true)
+	if err != nil {
+		return nil, err
+	}
+	return convert(as, func(answer engine.Term) (T, error) {
+		var v T
+		bindings, err := bindingsOf(answer)
+		if err != nil {
+			return v, err
+		}
+		err = scanBindings(bindings, reflect.ValueOf(&v).Elem())
+		return v, err
+	}), nil
+}
+
+func scanBindings(bindings []binding, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		byName := make(map[string]binding, len(bindings))
+		for _, b := range bindings {
+			byName[b.name] = b
+		}
+		for _, f := range prologFieldsOf(v.Type()) {
+			key, ok := findKey(byName, f.name)
+			if !ok {
+				continue
+			}
+			b := byName[key]
+			if err := scanProlog(b.value, v.FieldByIndex(f.index), b.name, b.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key() != stringType {
+			return fmt.Errorf("pengine: can't scan into %s: map keys must be strings", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(bindings)))
+		}
+		for _, b := range bindings {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := scanProlog(b.value, elem, b.name, b.name); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(b.name), elem)
+		}
+		return nil
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(bindings), len(bindings)))
+		for i, b := range bindings {
+			if err := scanProlog(b.value, v.Index(i), b.name, b.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("pengine: can't scan into %s: want struct, map, or slice", v.Type())
+}
+
+// prologFieldsOf returns the fields of a struct type, named by their prolog tag, pengine tag, or field name.
+func prologFieldsOf(typ reflect.Type) []field {
+	fields := fieldsOf(typ)
+	for i, f := range fields {
+		if tag, ok := typ.FieldByIndex(f.index).Tag.Lookup("prolog"); ok && tag != "" {
+			fields[i].name = tag
+		}
+	}
+	return fields
+}
+
+// scanProlog converts t like ichiban/prolog's Solutions.Scan.
+func scanProlog(t engine.Term, v reflect.Value, name, path string) error {
+	fail := func(reason string) error {
+		term, _ := termOf(t)
+		return &ScanError{Var: name, Path: path, Type: v.Type(), Term: term, Reason: reason}
+	}
+
+	if v.Type() == prologTermType || (v.Kind() == reflect.Interface && v.NumMethod() == 0) {
+		if t != nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, ok := t.(engine.Float)
+		if !ok {
+			return fail("want float")
+		}
+		v.SetFloat(float64(f))
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := t.(engine.Integer)
+		if !ok {
+			return fail("want integer")
+		}
+		v.Set(reflect.ValueOf(n).Convert(v.Type()))
+		return nil
+	case reflect.String:
+		a, ok := t.(engine.Atom)
+		if !ok {
+			return fail("want atom")
+		}
+		v.SetString(string(a))
+		return nil
+	case reflect.Slice:
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		iter := engine.ListIterator{List: t}
+		for i := 0; iter.Next(); i++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := scanProlog(iter.Current(), elem, name, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+			list = reflect.Append(list, elem)
+		}
+		if err := iter.Err(); err != nil {
+			return fail("want list")
+		}
+		v.Set(list)
+		return nil
+	}
+	return fail("unsupported type")
+}
@@ -0,0 +1,280 @@
+package pengine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestSolutionScan(t *testing.T) {
+	type address struct {
+		City string
+		Zip  int `pengine:"zip"`
+	}
+	type pair struct {
+		Key   string
+		Value float64
+	}
+	type row struct {
+		Name    string   `pengine:"N"`
+		Age     uint8    `pengine:"A"`
+		Tags    []string `pengine:"T"`
+		Big     *big.Int `pengine:"B"`
+		Home    address  `pengine:"H"`
+		Pair    pair     `pengine:"P"`
+		Ok      bool
+		Any     any
+		Missing string
+		Ignored string `pengine:"-"`
+	}
+
+	var sol Solution
+	raw := `{
+		"N": "alice",
+		"A": 42,
+		"T": ["a", "b"],
+		"B": 123456789012345678901234567890,
+		"H": {"city": "Tokyo", "zip": 1000001},
+		"P": {"functor": "-", "args": ["pi", 3.14]},
+		"Ok": true,
+		"Any": [1, 2.5, "x"],
+		"Ignored": "nope"
+	}`
+	if err := json.Unmarshal([]byte(raw), &sol); err != nil {
+		t.Fatal(err)
+	}
+
+	got := row{Missing: "untouched"}
+	if err := sol.Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	bigWant, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	want := row{
+		Name:    "alice",
+		Age:     42,
+		Tags:    []string{"a", "b"},
+		Big:     bigWant,
+		Home:    address{City: "Tokyo", Zip: 1000001},
+		Pair:    pair{Key: "pi", Value: 3.14},
+		Ok:      true,
+		Any:     []any{int64(1), 2.5, "x"},
+		Missing: "untouched",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bad scan.\nwant: %+v\ngot:  %+v", want, got)
+	}
+
+	var m map[string]any
+	if err := sol.Scan(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m["N"] != "alice" || m["A"] != int64(42) {
+		t.Error("bad map scan:", m)
+	}
+}
+
+func TestScanFields(t *testing.T) {
+	type Base struct {
+		ID   int
+		Name string
+	}
+	type row struct {
+		Base
+		Name string // shadows Base.Name
+		Kind string
+	}
+	var sol Solution
+	raw := `{"id": 7, "Name": "outer", "kind": "x", "Kind": "exact", "D": {"id": 8, "kind": "y"}}`
+	if err := json.Unmarshal([]byte(raw), &sol); err != nil {
+		t.Fatal(err)
+	}
+
+	var got row
+	if err := sol.Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := row{Base: Base{ID: 7}, Name: "outer", Kind: "exact"}
+	if got != want {
+		t.Errorf("bad solution scan.\nwant: %+v\ngot:  %+v", want, got)
+	}
+
+	// dicts follow the same rules as solutions
+	var dict row
+	if err := sol["D"].Scan(&dict); err != nil {
+		t.Fatal(err)
+	}
+	if want := (row{Base: Base{ID: 8}, Kind: "y"}); dict != want {
+		t.Errorf("bad dict scan.\nwant: %+v\ngot:  %+v", want, dict)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	tests := []struct {
+		raw  string
+		dst  any
+		path string
+	}{
+		{raw: `{"X": "foo"}`, dst: &struct{ X int }{}, path: "X"},
+		{raw: `{"X": 300}`, dst: &struct{ X int8 }{}, path: "X"},
+		{raw: `{"X": -1}`, dst: &struct{ X uint }{}, path: "X"},
+		{raw: `{"X": 1.5}`, dst: &struct{ X int }{}, path: "X"},
+		{raw: `{"X": [1, "two"]}`, dst: &struct{ X []int }{}, path: "X[1]"},
+		{raw: `{"X": {"a": {"b": "c"}}}`, dst: &struct{ X map[string]struct{ B int } }{}, path: "X.a.b"},
+		{raw: `{"X": {"functor": "f", "args": [1]}}`, dst: &struct{ X struct{ A, B int } }{}, path: "X"},
+	}
+	for _, test := range tests {
+		var sol Solution
+		if err := json.Unmarshal([]byte(test.raw), &sol); err != nil {
+			t.Fatal(err)
+		}
+		err := sol.Scan(test.dst)
+		var serr *ScanError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: want ScanError, got: %v", test.raw, err)
+			continue
+		}
+		if serr.Var != "X" || serr.Path != test.path {
+			t.Errorf("%s: want path %s, got: %s (%v)", test.raw, test.path, serr.Path, err)
+		}
+	}
+}
+
+func TestAskInto(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	type row struct {
+		X int
+	}
+	as, err := AskInto[row](ctx, srv.client(), "between(1,3,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []row
+	for as.Next(ctx) {
+		got = append(got, as.Current())
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []row{{1}, {2}, {3}}; !reflect.DeepEqual(got, want) {
+		t.Error("want:", want, "got:", got)
+	}
+
+	type bad struct {
+		X string
+	}
+	bs, err := AskInto[bad](ctx, srv.client(), "between(1,3,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.Next(ctx) {
+		t.Error("scan should fail")
+	}
+	var serr *ScanError
+	if !errors.As(bs.Err(), &serr) {
+		t.Error("want ScanError, got:", bs.Err())
+	}
+	if n := srv.alive(); n != 0 {
+		t.Error("query should be closed after a scan error. alive:", n)
+	}
+}
+
+func TestScanBindings(t *testing.T) {
+	var c Client
+	template, err := c.bindingsTemplate("foo(X, Y, _Z, [W|_])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "['X'=X,'Y'=Y,'W'=W]"; template != want {
+		t.Error("bad template. want:", want, "got:", template)
+	}
+	answer, _, err := c.parseQuery(`['X'=1, 'Y'=text, 'W'=[1.5, 2.5]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings, err := bindingsOf(answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		X    int
+		Name string `prolog:"y"` // matched case-insensitively
+		W    []float64
+	}
+	var got row
+	if err := scanBindings(bindings, reflect.ValueOf(&got)); err != nil {
+		t.Fatal(err)
+	}
+	if want := (row{X: 1, Name: "text", W: []float64{1.5, 2.5}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %+v got: %+v", want, got)
+	}
+
+	var m map[string]engine.Term
+	if err := scanBindings(bindings, reflect.ValueOf(&m)); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 || m["X"] != engine.Integer(1) {
+		t.Error("bad map:", m)
+	}
+
+	var list []any
+	if err := scanBindings(bindings, reflect.ValueOf(&list)); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0] != engine.Integer(1) {
+		t.Error("bad slice:", list)
+	}
+
+	// conversions that Solutions.Scan doesn't make
+	bad := []struct {
+		v    any
+		name string
+	}{
+		{&struct{ X string }{}, "X"},
+		{&struct{ X float64 }{}, "X"},
+		{&struct{ X bool }{}, "X"},
+		{&struct{ W []int }{}, "W"},
+	}
+	for _, test := range bad {
+		err := scanBindings(bindings, reflect.ValueOf(test.v))
+		var serr *ScanError
+		if !errors.As(err, &serr) || serr.Var != test.name {
+			t.Errorf("%T: want ScanError for %s, got: %v", test.v, test.name, err)
+		}
+	}
+}
+
+func TestScanArgs(t *testing.T) {
+	type pair struct {
+		_ struct{} `pengine:"p"`
+		A int
+		B int `pengine:",arg=1"`
+	}
+	in := pair{A: 1, B: 2}
+	pt, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stringify(pt); got != "p(2,1)" {
+		t.Fatal("want p(2,1), got:", got)
+	}
+
+	term, err := termOf(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pair
+	if err := term.Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != in {
+		t.Errorf("want: %+v got: %+v", in, got)
+	}
+}
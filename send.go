@@ -6,52 +6,212 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/ichiban/prolog/engine"
 )
 
-func (e *Engine) send(ctx context.Context, body string) (answer, error) {
-	var v answer
-	r := strings.NewReader(body + "\n.")
+const (
+	// maxErrorBody is the maximum number of bytes of an unexpected response body kept in HTTPError.
+	maxErrorBody = 512
+	// maxErrorEvent is the maximum number of bytes read from a response with an unexpected status.
+	maxErrorEvent = 64 << 10
+)
+
+// do performs an API request, authenticating it and running the client's middleware,
+// and returns the response body. reqBody is only used for logging.
+// The request is recorded in stats, the query it belongs to, unless stats is nil.
+// If the server responds with an unexpected status, the body is decoded as an error event if possible.
+func (e *Engine) do(req *http.Request, stats *queryStats, action, format, reqBody string) ([]byte, error) {
+	info := RequestInfo{EngineID: e.ID(), Action: action, Format: format}
+	start := time.Now()
+	resp, err := e.roundTrip(req, info)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && e.reauthenticate(resp) {
+		// retry once with a fresh token or digest challenge
+		resp.Body.Close()
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err = e.roundTrip(req, info)
+	}
+	if err != nil {
+		e.logRequest(req.Context(), info, start, 0, reqBody, nil, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if resp.StatusCode == http.StatusOK {
+		body, err = io.ReadAll(resp.Body)
+	} else {
+		// only an error event is decoded from these, so don't read an unbounded body
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxErrorEvent))
+	}
+	if stats != nil {
+		stats.request(start, len(body))
+	}
+	e.logRequest(req.Context(), info, start, resp.StatusCode, reqBody, body, err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := e.statusError(resp, body)
+		if err == ErrDead {
+			e.die()
+		}
+		return nil, err
+	}
+	return body, nil
+}
 
-	if e.debug {
-		log.Printf("pengine(%s) → send0: %s", e.id, body)
+func (e *Engine) roundTrip(req *http.Request, info RequestInfo) (*http.Response, error) {
+	if e.client.Auth != nil {
+		if err := e.client.Auth.Authenticate(req); err != nil {
+			return nil, fmt.Errorf("pengine: authentication failed: %w", err)
+		}
 	}
+	return e.client.runMiddleware(req, info)
+}
+
+// reauthenticate updates the client's credentials after a 401 response,
+// such as invalidating a bearer token or taking a digest challenge,
+// returning true if the request should be retried.
+func (e *Engine) reauthenticate(resp *http.Response) bool {
+	auth, ok := e.client.Auth.(interface{ reauthenticate(*http.Response) bool })
+	return ok && auth.reauthenticate(resp)
+}
 
-	href := fmt.Sprintf("%s/send?format=json&id=%s", e.client.URL, url.QueryEscape(e.id))
+// statusError decodes the body of a response with an unexpected status into an error.
+// Unlike handling an answer, this doesn't update the engine's state.
+func (e *Engine) statusError(resp *http.Response, body []byte) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		var a answer
+		if err := json.Unmarshal(body, &a); err == nil {
+			if err := eventError(a); err != nil {
+				return err
+			}
+		}
+	case strings.HasSuffix(mediaType, "prolog"):
+		parser := e.client.interpreter().Parser(strings.NewReader(string(body)), nil)
+		if t, err := parser.Term(); err == nil {
+			if err := e.prologEventError(t); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &HTTPError{
+		StatusCode:  resp.StatusCode,
+		ContentType: contentType,
+		Body:        string(body),
+	}
+}
+
+// eventError returns the error reported by a JSON-format event, or nil if there is none.
+func eventError(a answer) error {
+	switch a.Event {
+	case "error":
+		if isAborted(engine.Atom(a.Code)) {
+			return ErrAborted
+		}
+		if perr, err := newError(a); err == nil {
+			return perr
+		}
+	case "died":
+		return ErrDead
+	case "abort":
+		return ErrAborted
+	case "destroy":
+		var child answer
+		if len(a.Data) > 0 && json.Unmarshal(a.Data, &child) == nil {
+			if err := eventError(child); err != nil {
+				return err
+			}
+		}
+	}
+	if a.Answer != nil {
+		return eventError(*a.Answer)
+	}
+	return nil
+}
+
+// prologEventError returns the error reported by a Prolog-format event, or nil if there is none.
+func (e *Engine) prologEventError(t engine.Term) error {
+	event, ok := t.(engine.Compound)
+	if !ok {
+		return nil
+	}
+	switch {
+	case event.Functor() == "error" && event.Arity() == 2:
+		ball := event.Arg(1)
+		if isAborted(ball) {
+			return ErrAborted
+		}
+		ex := &Exception{
+			Ball: resolve(ball, nil, nil),
+			ID:   e.ID(),
+			URL:  e.client.URL,
+		}
+		if id, ok := event.Arg(0).(engine.Atom); ok {
+			ex.ID = string(id)
+		}
+		return ex
+	case event.Functor() == "died":
+		return ErrDead
+	case event.Functor() == "abort":
+		return ErrAborted
+	case event.Functor() == "destroy" && event.Arity() == 2:
+		return e.prologEventError(event.Arg(1))
+	}
+	return nil
+}
+
+func (e *Engine) send(ctx context.Context, stats *queryStats, body string) (answer, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+	id := e.ID()
+
+	var v answer
+	r := strings.NewReader(body + "\n.")
+
+	href := fmt.Sprintf("%s/send?format=%s&id=%s", e.client.URL, url.QueryEscape(e.client.jsonFormat()), url.QueryEscape(id))
 	req, err := http.NewRequestWithContext(ctx, "POST", href, r)
 	if err != nil {
 		return v, err
 	}
 	req.Header.Set("Content-Type", "application/x-prolog; charset=utf-8")
 
-	resp, err := e.client.client().Do(req)
+	resp, err := e.do(req, stats, "send", e.client.jsonFormat(), body)
 	if err != nil {
 		return v, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return v, fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
-
-	if e.debug {
-		log.Printf("pengine(%s) ← receive json...", e.id)
-		dump, _ := httputil.DumpResponse(resp, true)
-		log.Println(string(dump))
-	}
 
-	err = json.NewDecoder(resp.Body).Decode(&v)
+	err = json.Unmarshal(resp, &v)
 	return v, err
 }
 
-func (e *Engine) get(ctx context.Context, action string, format string) (answer, error) {
+func (e *Engine) get(ctx context.Context, stats *queryStats, action string, format string) (answer, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+	id := e.ID()
+
 	var v answer
 
 	params := url.Values{}
-	params.Set("id", e.id)
+	params.Set("id", id)
 	params.Set("format", format)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", e.client.URL+"/"+action+"?"+params.Encode(), nil)
@@ -60,27 +220,36 @@ func (e *Engine) get(ctx context.Context, action string, format string) (answer,
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-	resp, err := e.client.client().Do(req)
+	resp, err := e.do(req, stats, action, format, "")
 	if err != nil {
 		return v, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return v, fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
+	err = json.Unmarshal(resp, &v)
+	return v, err
+}
+
+func (e *Engine) abort(ctx context.Context) error {
+	id := e.ID()
 
-	if e.debug {
-		log.Printf("pengine(%s) ← got json...", e.id)
-		dump, _ := httputil.DumpResponse(resp, true)
-		log.Println(string(dump))
+	params := url.Values{}
+	params.Set("id", id)
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", e.client.URL+"/abort?"+params.Encode(), nil)
+	if err != nil {
+		return err
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&v)
-	return v, err
+	_, err = e.do(req, nil, "abort", "json", "")
+	return err
 }
 
-func (e *Engine) post(ctx context.Context, action string, body any) (answer, error) {
+func (e *Engine) post(ctx context.Context, stats *queryStats, action string, body any) (answer, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+	id := e.ID()
+
 	var v answer
 	var r io.Reader
 	if body != nil {
@@ -91,13 +260,9 @@ func (e *Engine) post(ctx context.Context, action string, body any) (answer, err
 		r = bytes.NewReader(bs)
 	}
 
-	if e.debug {
-		log.Printf("pengine(%s) → post JSON: %s", e.id, body)
-	}
-
 	var param string
-	if e.id != "" {
-		param = "?id=" + url.QueryEscape(e.id)
+	if id != "" {
+		param = "?id=" + url.QueryEscape(id)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", e.client.URL+"/"+action+param, r)
@@ -106,99 +271,76 @@ func (e *Engine) post(ctx context.Context, action string, body any) (answer, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-	resp, err := e.client.client().Do(req)
+	resp, err := e.do(req, stats, action, e.client.jsonFormat(), e.loggable(body))
 	if err != nil {
 		return v, err
 	}
-	defer resp.Body.Close()
-
-	// if e.debug {
-	// 	rrr, _ := httputil.DumpResponse(resp, true)
-	// 	fmt.Println("GOT: ", string(rrr))
-	// }
 
-	if resp.StatusCode != http.StatusOK {
-		return v, fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
-
-	if e.debug {
-		dump, _ := httputil.DumpResponse(resp, true)
-		log.Println(string(dump))
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&v)
+	err = json.Unmarshal(resp, &v)
 	return v, err
 }
 
-func (e *Engine) sendProlog(ctx context.Context, body string) (string, error) {
-	r := strings.NewReader(body + "\n.")
+func (e *Engine) sendProlog(ctx context.Context, stats *queryStats, body string) (string, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+	id := e.ID()
 
-	if e.debug {
-		log.Printf("pengine(%s) → send prolog: %s", e.id, body)
-	}
+	r := strings.NewReader(body + "\n.")
 
-	href := fmt.Sprintf("%s/send?format=prolog&id=%s", e.client.URL, url.QueryEscape(e.id))
+	href := fmt.Sprintf("%s/send?format=prolog&id=%s", e.client.URL, url.QueryEscape(id))
 	req, err := http.NewRequestWithContext(ctx, "POST", href, r)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/x-prolog; charset=utf-8")
 
-	resp, err := e.client.client().Do(req)
+	resp, err := e.do(req, stats, "send", "prolog", body)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
+	return string(resp), nil
+}
+
+func (e *Engine) getProlog(ctx context.Context, stats *queryStats, action string) (string, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+	id := e.ID()
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, resp.Body); err != nil {
+	params := url.Values{}
+	params.Set("id", id)
+	params.Set("format", "prolog")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", e.client.URL+"/"+action+"?"+params.Encode(), nil)
+	if err != nil {
 		return "", err
 	}
 
-	if e.debug {
-		log.Printf("pengine(%s) ← got prolog: %s", e.id, buf.String())
+	resp, err := e.do(req, stats, action, "prolog", "")
+	if err != nil {
+		return "", err
 	}
-
-	return buf.String(), nil
+	return string(resp), nil
 }
 
-func (e *Engine) postProlog(action string, body any) (string, error) {
+func (e *Engine) postProlog(ctx context.Context, stats *queryStats, action string, body any) (string, error) {
+	e.reqMu.Lock()
+	defer e.reqMu.Unlock()
+
 	bs, err := json.Marshal(body)
 	if err != nil {
 		return "", err
 	}
 	r := bytes.NewReader(bs)
 
-	if e.debug {
-		log.Printf("pengine(%s) → post prolog: %s", e.id, string(bs))
-	}
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s?format=prolog", e.client.URL, action), r)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s?format=prolog", e.client.URL, action), r)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-	resp, err := e.client.client().Do(req)
+	resp, err := e.do(req, stats, action, "prolog", e.loggable(body))
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %d", resp.StatusCode)
-	}
-
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, resp.Body); err != nil {
-		return "", err
-	}
-
-	if e.debug {
-		log.Printf("pengine(%s) ← got prolog: %s", e.id, buf.String())
-	}
-
-	return buf.String(), nil
+	return string(resp), nil
 }
@@ -0,0 +1,105 @@
+package pengine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		check       func(t *testing.T, err error)
+	}{
+		{
+			name:        "json error event",
+			contentType: "application/json; charset=UTF-8",
+			body:        `{"event":"error","id":"x","code":"existence_error","arg1":"pengine","arg2":"x","data":"Unknown pengine: x"}`,
+			check: func(t *testing.T, err error) {
+				var perr Error
+				if !errors.As(err, &perr) {
+					t.Fatal("want Error, got:", err)
+				}
+				if !IsExistenceError(err) {
+					t.Error("want existence error, got:", err)
+				}
+			},
+		},
+		{
+			name:        "json died event",
+			contentType: "application/json",
+			body:        `{"event":"died","id":"x"}`,
+			check: func(t *testing.T, err error) {
+				if err != ErrDead {
+					t.Error("want:", ErrDead, "got:", err)
+				}
+			},
+		},
+		{
+			name:        "json error inside create event",
+			contentType: "application/json",
+			body:        `{"event":"create","id":"other","slave_limit":1,"answer":{"event":"error","id":"other","code":"existence_error","arg1":"procedure","arg2":"foo/0","data":"Unknown procedure: foo/0"}}`,
+			check: func(t *testing.T, err error) {
+				if !IsExistenceError(err) {
+					t.Error("want existence error, got:", err)
+				}
+			},
+		},
+		{
+			name:        "prolog error event",
+			contentType: "text/x-prolog; charset=UTF-8",
+			body:        "error(x,error(permission_error(call,sandboxed,shell(ls)),_)).\n",
+			check: func(t *testing.T, err error) {
+				var ex *Exception
+				if !errors.As(err, &ex) {
+					t.Fatal("want *Exception, got:", err)
+				}
+				if !IsPermissionError(err) {
+					t.Error("want permission error, got:", err)
+				}
+			},
+		},
+		{
+			name:        "html",
+			contentType: "text/html",
+			body:        "<h1>Not Found</h1>",
+			check: func(t *testing.T, err error) {
+				var herr *HTTPError
+				if !errors.As(err, &herr) {
+					t.Fatal("want *HTTPError, got:", err)
+				}
+				if herr.StatusCode != http.StatusNotFound || herr.ContentType != "text/html" || herr.Body != "<h1>Not Found</h1>" {
+					t.Errorf("unexpected error: %#v", herr)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+
+			eng := &Engine{id: "x", client: Client{URL: srv.URL}}
+			var err error
+			if test.contentType == "text/x-prolog; charset=UTF-8" {
+				_, err = eng.sendProlog(context.Background(), nil, "next")
+			} else {
+				_, err = eng.send(context.Background(), nil, "next")
+			}
+			test.check(t, err)
+			// decoding the error must not touch the engine's state
+			if id, limit := eng.ID(), eng.limit(); id != "x" || limit != 0 {
+				t.Errorf("engine state changed: id=%q limit=%d", id, limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package pengine
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats are statistics about a query, useful for tuning Client.Chunk.
+type Stats struct {
+	// RoundTrips is the number of HTTP requests made for this query,
+	// including creating its pengine when the query was given at creation.
+	RoundTrips int
+	// Chunks is the number of success events received.
+	Chunks int
+	// Solutions is the number of answers received.
+	Solutions int
+	// BytesRead is the number of response body bytes read.
+	BytesRead int64
+	// WallTime is the client-side time elapsed from the query's first request
+	// until it finished, or until now if it is still running.
+	WallTime time.Duration
+	// ServerTime is the time taken by each chunk, as reported by pengines.
+	ServerTime []time.Duration
+}
+
+// queryStats collects Stats for a query.
+// It has its own lock because requests are recorded while the query's lock is held elsewhere.
+type queryStats struct {
+	mu    sync.Mutex
+	stats Stats
+	start time.Time
+	end   time.Time
+}
+
+func (qs *queryStats) request(start time.Time, bytes int) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if qs.start.IsZero() {
+		qs.start = start
+	}
+	qs.stats.RoundTrips++
+	qs.stats.BytesRead += int64(bytes)
+}
+
+func (qs *queryStats) chunk(solutions int, seconds float64) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	qs.stats.Chunks++
+	qs.stats.Solutions += solutions
+	qs.stats.ServerTime = append(qs.stats.ServerTime, time.Duration(float64(time.Second)*seconds))
+}
+
+// merge adds the requests recorded in other, such as those made to create this query's pengine.
+func (qs *queryStats) merge(other *queryStats) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if !other.start.IsZero() && (qs.start.IsZero() || other.start.Before(qs.start)) {
+		qs.start = other.start
+	}
+	qs.stats.RoundTrips += other.stats.RoundTrips
+	qs.stats.BytesRead += other.stats.BytesRead
+}
+
+func (qs *queryStats) finish() {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	if qs.end.IsZero() {
+		qs.end = time.Now()
+	}
+}
+
+func (qs *queryStats) snapshot() Stats {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	stats := qs.stats
+	stats.ServerTime = append([]time.Duration(nil), qs.stats.ServerTime...)
+	switch {
+	case qs.start.IsZero():
+	case qs.end.IsZero():
+		stats.WallTime = time.Since(qs.start)
+	default:
+		stats.WallTime = qs.end.Sub(qs.start)
+	}
+	return stats
+}
+
+// statser is implemented by queries that collect Stats.
+type statser interface {
+	queryStats() *queryStats
+}
+
+// queryStats returns the stats of the running query, or nil if there is none.
+func (e *Engine) queryStats() *queryStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if q, ok := e.query.(statser); ok {
+		return q.queryStats()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package pengine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	c := srv.client()
+	c.Chunk = 2
+
+	as, err := c.Ask(ctx, "between(1,5,X)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for as.Next(ctx) {
+		n++
+	}
+	if err := as.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := as.Stats()
+	// create (with answers 1,2), next (3,4), next (5)
+	if stats.RoundTrips != 3 {
+		t.Error("want 3 round trips, got:", stats.RoundTrips)
+	}
+	if stats.Chunks != 3 || len(stats.ServerTime) != 3 {
+		t.Error("want 3 chunks, got:", stats.Chunks, stats.ServerTime)
+	}
+	if stats.Solutions != n || n != 5 {
+		t.Error("want 5 solutions, got:", stats.Solutions, n)
+	}
+	if stats.BytesRead == 0 {
+		t.Error("no bytes read")
+	}
+	if stats.WallTime <= 0 {
+		t.Error("no wall time")
+	}
+	if again := as.Stats(); again.WallTime != stats.WallTime {
+		t.Error("wall time should stop when the query finishes")
+	}
+
+	t.Run("engine", func(t *testing.T) {
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		if err := eng.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+		as, err := eng.Ask(ctx, "between(1,3,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for as.Next(ctx) {
+		}
+		// ask (1,2), next (3)
+		if stats := as.Stats(); stats.RoundTrips != 2 || stats.Solutions != 3 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("ping during query", func(t *testing.T) {
+		eng, err := c.Create(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+		as, err := eng.Ask(ctx, "between(1,3,X)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// pings aren't part of the query, even while it's running
+		if err := eng.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for as.Next(ctx) {
+		}
+		if stats := as.Stats(); stats.RoundTrips != 2 || stats.Solutions != 3 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -24,8 +26,13 @@ type Solution map[string]Term
 // Term represents a Prolog term.
 // One of the fields should be "truthy".
 // This can be handy for parsing query results in JSON format.
+//
+// The plain JSON format does not distinguish between atoms, strings, and variables, so they are all decoded as Atom
+// (except for "_", which is decoded as Variable).
+// Use the json-s format (see Client.JSONFormat) to decode String and Variable accurately.
 type Term struct {
 	Atom       *string
+	String     *string
 	Number     *json.Number
 	Compound   *Compound
 	Variable   *string
@@ -48,8 +55,7 @@ func (t *Term) UnmarshalJSON(b []byte) error {
 	case json.Number:
 		t.Number = &x
 	case string:
-		// TODO: need to figure out how to disambiguate var(_) / atom('_')
-		// maybe you can't? or need to use prolog instead of json format?
+		// the json format can't disambiguate var(_) / atom('_'), json-s can.
 		if x == "_" {
 			variable := x
 			t.Variable = &variable
@@ -90,52 +96,73 @@ func (t *Term) UnmarshalJSON(b []byte) error {
 // Prolog converts this term to an ichiban/prolog term.
 // Because pengine's JSON format is lossy in terms of Prolog types, this might not always be accurate.
 // There is ambiguity between atoms, strings, and variables.
+// Integers larger than 64 bits are returned as opaque terms that engine.WriteTerm writes as-is, as with Marshal.
 // If you are mainly dealing with Prolog terms, use AskProlog to use the Prolog format instead.
+// Prolog panics if the term holds a malformed number.
 func (t Term) Prolog() engine.Term {
+	pt, err := t.prolog()
+	if err != nil {
+		panic(err)
+	}
+	return pt
+}
+
+// prolog is like Prolog, but returns an error instead of panicking.
+func (t Term) prolog() (engine.Term, error) {
 	switch {
 	case t.Atom != nil:
-		return engine.Atom(*t.Atom)
+		return engine.Atom(*t.Atom), nil
+	case t.String != nil:
+		return engine.CharList(*t.String), nil
 	case t.Number != nil:
 		// TODO(guregu): fix/document/make optional the Int/Float detection.
 		nstr := string(*t.Number)
 		if strings.ContainsRune(nstr, '.') {
 			f, err := strconv.ParseFloat(nstr, 64)
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("pengine: invalid number %s: %w", nstr, err)
 			}
-			return engine.Float(f)
+			return engine.Float(f), nil
 		}
-		n, err := strconv.ParseInt(nstr, 10, 64)
-		if err != nil {
-			panic(err)
+		n, ok := new(big.Int).SetString(nstr, 10)
+		if !ok {
+			return nil, fmt.Errorf("pengine: invalid number %s", nstr)
 		}
-		return engine.Integer(n)
+		return marshalBigInt(n), nil
 	case t.Compound != nil:
 		args := make([]engine.Term, 0, len(t.Compound.Args))
 		for _, arg := range t.Compound.Args {
-			args = append(args, arg.Prolog())
+			pt, err := arg.prolog()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, pt)
 		}
-		return engine.Atom(t.Compound.Functor).Apply(args...)
+		return engine.Atom(t.Compound.Functor).Apply(args...), nil
 	case t.Variable != nil:
 		// TODO(guregu): what should this be? engine.NewVariable? Is this even useful?
-		return engine.Variable(*t.Variable)
+		return engine.Variable(*t.Variable), nil
 	case t.Boolean != nil:
 		// TODO(guregu): use `@(true)` instead?
 		if *t.Boolean {
-			return engine.Atom("true")
+			return engine.Atom("true"), nil
 		} else {
-			return engine.Atom("false")
+			return engine.Atom("false"), nil
 		}
 	case t.List != nil:
 		list := make([]engine.Term, 0, len(t.List))
 		for _, member := range t.List {
-			list = append(list, member.Prolog())
+			pt, err := member.prolog()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, pt)
 		}
-		return engine.List(list...)
+		return engine.List(list...), nil
 	case t.Null:
-		return engine.Atom("null") // TODO(guregu): use `@(null)`?
+		return engine.Atom("null"), nil // TODO(guregu): use `@(null)`?
 	}
-	return nil
+	return nil, nil
 }
 
 // Compound is a Prolog compound: functor(args0, args1, ...).
@@ -144,6 +171,83 @@ type Compound struct {
 	Args    []Term `json:"args"`
 }
 
+// termOf converts an ichiban/prolog term to a Term.
+// Character and code lists produced by parsing double-quoted text are converted to strings.
+func termOf(t engine.Term) (Term, error) {
+	switch t := t.(type) {
+	case engine.Atom:
+		if t == "[]" {
+			return Term{List: []Term{}}, nil
+		}
+		atom := string(t)
+		return Term{Atom: &atom}, nil
+	case engine.Integer:
+		n := json.Number(strconv.FormatInt(int64(t), 10))
+		return Term{Number: &n}, nil
+	case engine.Float:
+		str := strconv.FormatFloat(float64(t), 'g', -1, 64)
+		if !strings.ContainsRune(str, '.') {
+			// keep the decimal point so Prolog can tell it's a float
+			if i := strings.IndexRune(str, 'e'); i != -1 {
+				str = str[:i] + ".0" + str[i:]
+			} else {
+				str += ".0"
+			}
+		}
+		n := json.Number(str)
+		return Term{Number: &n}, nil
+	case engine.Variable:
+		name := string(t)
+		return Term{Variable: &name}, nil
+	case engine.Compound:
+		if str, ok := quotedText(t); ok {
+			return Term{String: &str}, nil
+		}
+		if t.Functor() == "." && t.Arity() == 2 {
+			list := []Term{}
+			iter := engine.ListIterator{List: t}
+			for iter.Next() {
+				member, err := termOf(iter.Current())
+				if err != nil {
+					return Term{}, err
+				}
+				list = append(list, member)
+			}
+			if err := iter.Err(); err != nil {
+				return Term{}, err
+			}
+			return Term{List: list}, nil
+		}
+		c := &Compound{
+			Functor: string(t.Functor()),
+			Args:    make([]Term, 0, t.Arity()),
+		}
+		for i := 0; i < t.Arity(); i++ {
+			arg, err := termOf(t.Arg(i))
+			if err != nil {
+				return Term{}, err
+			}
+			c.Args = append(c.Args, arg)
+		}
+		return Term{Compound: c}, nil
+	}
+	return Term{}, fmt.Errorf("pengine: can't convert term of type %T", t)
+}
+
+var (
+	charListType = reflect.TypeOf(engine.CharList("_"))
+	codeListType = reflect.TypeOf(engine.CodeList("_"))
+)
+
+// quotedText returns the text of a list produced by parsing a double-quoted string.
+func quotedText(t engine.Compound) (string, bool) {
+	switch reflect.TypeOf(t) {
+	case charListType, codeListType:
+		return reflect.ValueOf(t).String(), true
+	}
+	return "", false
+}
+
 func escapeAtom(atom string) string {
 	return stringify(engine.Atom(atom))
 }
@@ -0,0 +1,65 @@
+package pengine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestJSONS(t *testing.T) {
+	raw := json.RawMessage(`[{"A": "'_'", "B": "_123", "C": "\"str\"", "D": "foo(bar, [1, 2.5])", "E": "[]"}]`)
+	sols, err := decodeAnswers[Solution](Client{JSONFormat: FormatJSONS}, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sols) != 1 {
+		t.Fatal("unexpected length:", len(sols))
+	}
+	sol := sols[0]
+
+	if sol["A"].Atom == nil || *sol["A"].Atom != "_" {
+		t.Error("want atom '_', got:", sol["A"])
+	}
+	if sol["B"].Variable == nil || *sol["B"].Variable != "_123" {
+		t.Error("want variable _123, got:", sol["B"])
+	}
+	if sol["C"].String == nil || *sol["C"].String != "str" {
+		t.Error("want string \"str\", got:", sol["C"])
+	}
+	want := engine.Atom("foo").Apply(engine.Atom("bar"), engine.List(engine.Integer(1), engine.Float(2.5)))
+	if got := sol["D"].Prolog(); !reflect.DeepEqual(want, got) {
+		t.Error("bad compound. want:", want, "got:", got)
+	}
+	if sol["E"].List == nil || len(sol["E"].List) != 0 {
+		t.Error("want empty list, got:", sol["E"])
+	}
+
+	// template answers are sent as Prolog text
+	terms, err := decodeAnswers[Term](Client{JSONFormat: FormatJSONS}, json.RawMessage(`["f(\"s\", _, 'A')", {"X": "1"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != 2 {
+		t.Fatal("unexpected length:", len(terms))
+	}
+	if f := terms[0].Compound; f == nil || f.Functor != "f" || f.Args[0].String == nil || f.Args[1].Variable == nil || f.Args[2].Atom == nil {
+		t.Error("bad template answer:", terms[0])
+	}
+	if x := terms[1].Dictionary["X"]; x.Number == nil || *x.Number != "1" {
+		t.Error("bad bindings answer:", terms[1])
+	}
+
+	evt := newEvent(Client{JSONFormat: FormatJSONS}, answer{Event: "success", Data: raw})
+	if evt.Err != nil {
+		t.Fatal(evt.Err)
+	}
+	if len(evt.Data.List) != 1 || evt.Data.List[0].Dictionary["C"].String == nil {
+		t.Error("bad event data:", evt.Data)
+	}
+
+	if _, err := decodeAnswers[[]int](Client{JSONFormat: FormatJSONS}, raw); err == nil {
+		t.Error("expected error for unsupported answers")
+	}
+}